@@ -0,0 +1,516 @@
+package rfrouter
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/pkg/errors"
+)
+
+// ACLTarget is what an ACL rule applies to.
+type ACLTarget string
+
+const (
+	ACLRole    ACLTarget = "role"
+	ACLUser    ACLTarget = "user"
+	ACLChannel ACLTarget = "channel"
+)
+
+// ACLRule allows or denies a role, a user or a channel the use of a command,
+// or of all commands in a subcommand.
+type ACLRule struct {
+	// Command is the space-separated path of the command or subcommand, such
+	// as "music" or "music play", or empty for all commands.
+	Command string    `json:"command"`
+	Target  ACLTarget `json:"target"`
+	ID      string    `json:"id"`
+	Allow   bool      `json:"allow"`
+}
+
+func (r ACLRule) String() string {
+	var s = "deny "
+	if r.Allow {
+		s = "allow "
+	}
+
+	// Roles and users aren't mentioned, as that would ping them.
+	if r.Target == ACLChannel {
+		s += "<#" + r.ID + ">"
+	} else {
+		s += string(r.Target) + " " + r.ID
+	}
+
+	if r.Command == "" {
+		return s + " for all commands"
+	}
+
+	return s + " for " + r.Command
+}
+
+// applies returns true if the rule is for the command path.
+func (r ACLRule) applies(path string) bool {
+	return r.Command == "" || r.Command == path ||
+		strings.HasPrefix(path, r.Command+" ")
+}
+
+// ACLStore stores the ACL rules of each guild.
+type ACLStore interface {
+	// Rules returns the guild's rules, in the order they were added. The
+	// caller may modify the returned slice.
+	Rules(guildID string) ([]ACLRule, error)
+	// SetRules replaces all of the guild's rules.
+	SetRules(guildID string, rules []ACLRule) error
+}
+
+// ErrACLDenied is returned when the guild's ACL rules deny the user or their
+// roles the use of a command.
+type ErrACLDenied struct {
+	Command *CommandContext
+	// Rule is the rule that denied the command. For commands denied because
+	// only others are allowed, it's the allow rule that would've applied.
+	Rule ACLRule
+}
+
+func (err *ErrACLDenied) Error() string {
+	return "You are not allowed to use this command."
+}
+
+// ErrChannelRestricted is returned when the guild's ACL rules deny the use of a
+// command in the channel. If Context's IgnoreRestrictedChannels is true, these
+// commands are silently ignored instead.
+type ErrChannelRestricted struct {
+	Command   *CommandContext
+	ChannelID string
+	// Rule is the rule that denied the command, like ErrACLDenied's.
+	Rule ACLRule
+}
+
+func (err *ErrChannelRestricted) Error() string {
+	return "This command can't be used in this channel."
+}
+
+// checkACL checks the command against the guild's ACL rules. Rules are checked
+// separately for who uses the command, by user and role, and for where it's
+// used, by channel; both must allow it.
+//
+// For each, the rules for the most specific command path decide: a deny rule
+// matching the user or channel denies the command, otherwise a matching allow
+// rule allows it, otherwise any allow rules deny everything that they don't
+// match. If no rules are for the path, the rules of its subcommands decide,
+// up to the rules for all commands. Administrators and the subcommands managing
+// the rules are always allowed, and event handlers are only restricted by
+// channel.
+func (ctx *Context) checkACL(cmd *CommandContext, ev interface{}, isAdmin **bool) error {
+	// Otherwise, members who aren't administrators could lock themselves out
+	// of changing the rules back.
+	for sub := cmd.parent; sub != nil; sub = sub.parent {
+		if _, ok := sub.command.(aclManager); ok {
+			return nil
+		}
+	}
+
+	// Event handlers run for whoever caused the event, such as a member
+	// joining, so only the channel rules apply to them, and only to events that
+	// happened in a channel.
+	var message = cmd.event == typeMessageCreate
+	var channelID = reflectChannelID(ev)
+
+	if !message && channelID == "" {
+		return nil
+	}
+
+	var guildID = reflectGuildID(ev)
+	if ctx.ACL == nil || guildID == "" {
+		return nil
+	}
+
+	rules, err := ctx.ACL.Rules(guildID)
+	if err != nil {
+		return errors.Wrap(err, "Failed to get ACL rules")
+	}
+
+	if len(rules) == 0 || ctx.eventIsAdmin(ev, isAdmin) {
+		return nil
+	}
+
+	var path = strings.Join(append(cmd.parent.Path(), cmd.name), " ")
+	var userID = reflectUserID(ev)
+	var roles []string
+
+	for _, rule := range rules {
+		if message && rule.Target == ACLRole && rule.applies(path) {
+			member, err := ctx.Member(guildID, userID)
+			if err != nil {
+				return errors.Wrap(err, "Failed to get member")
+			}
+
+			roles = member.Roles
+			break
+		}
+	}
+
+	var who = func(r ACLRule) (bool, bool) {
+		switch r.Target {
+		case ACLUser:
+			return true, r.ID == userID
+		case ACLRole:
+			return true, contains(roles, r.ID)
+		}
+		return false, false
+	}
+
+	var where = func(r ACLRule) (bool, bool) {
+		return r.Target == ACLChannel, r.ID == channelID
+	}
+
+	if rule, ok := aclDenied(rules, path, who); !ok && message {
+		return &ErrACLDenied{Command: cmd, Rule: rule}
+	}
+
+	if rule, ok := aclDenied(rules, path, where); !ok {
+		return &ErrChannelRestricted{Command: cmd, ChannelID: channelID, Rule: rule}
+	}
+
+	return nil
+}
+
+// aclDenied decides whether the rules of one kind allow the command path. kind
+// returns whether a rule is of that kind, and whether it matches the event.
+// The deciding rule is returned if the command is denied.
+func aclDenied(rules []ACLRule, path string,
+	kind func(ACLRule) (isKind, matches bool)) (ACLRule, bool) {
+
+	// Walk up from the most specific path.
+	for command := path; ; {
+		var allowRule *ACLRule
+		var allowed bool
+
+		for i, r := range rules {
+			isKind, matches := kind(r)
+			if !isKind || r.Command != command {
+				continue
+			}
+
+			switch {
+			case !r.Allow && matches:
+				return r, false
+			case r.Allow && matches:
+				allowed = true
+			case r.Allow:
+				allowRule = &rules[i]
+			}
+		}
+
+		if allowed {
+			return ACLRule{}, true
+		}
+
+		if allowRule != nil {
+			return *allowRule, false
+		}
+
+		if command == "" {
+			return ACLRule{}, true
+		}
+
+		if i := strings.LastIndexByte(command, ' '); i >= 0 {
+			command = command[:i]
+		} else {
+			command = ""
+		}
+	}
+}
+
+// MemoryACLStore is an ACLStore that keeps rules in memory. It is safe for
+// concurrent use.
+type MemoryACLStore struct {
+	mutex sync.RWMutex
+	rules map[string][]ACLRule
+}
+
+var _ ACLStore = (*MemoryACLStore)(nil)
+
+func NewMemoryACLStore() *MemoryACLStore {
+	return &MemoryACLStore{
+		rules: map[string][]ACLRule{},
+	}
+}
+
+func (s *MemoryACLStore) Rules(guildID string) ([]ACLRule, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return append([]ACLRule(nil), s.rules[guildID]...), nil
+}
+
+func (s *MemoryACLStore) SetRules(guildID string, rules []ACLRule) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	setRules(s.rules, guildID, rules)
+	return nil
+}
+
+// set sets the rules. The mutex must be held.
+// setRules sets the guild's rules in the map.
+func setRules(guilds map[string][]ACLRule, guildID string, rules []ACLRule) {
+	if len(rules) == 0 {
+		delete(guilds, guildID)
+		return
+	}
+
+	guilds[guildID] = append([]ACLRule(nil), rules...)
+}
+
+// JSONACLStore is an ACLStore that keeps rules in memory, saving them into a
+// JSON file on every change. It is safe for concurrent use.
+type JSONACLStore struct {
+	MemoryACLStore
+	path string
+}
+
+var _ ACLStore = (*JSONACLStore)(nil)
+
+// NewJSONACLStore creates a store that loads from and saves into the JSON file
+// at path. The file is created on the first change if it doesn't exist.
+func NewJSONACLStore(path string) (*JSONACLStore, error) {
+	s := &JSONACLStore{
+		MemoryACLStore: *NewMemoryACLStore(),
+		path:           path,
+	}
+
+	if err := readJSONFile(path, &s.rules); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *JSONACLStore) SetRules(guildID string, rules []ACLRule) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// The rules are only changed in memory once they're saved.
+	var guilds = make(map[string][]ACLRule, len(s.rules)+1)
+	for id, r := range s.rules {
+		guilds[id] = r
+	}
+
+	setRules(guilds, guildID, rules)
+
+	if err := writeJSONFile(s.path, guilds); err != nil {
+		return err
+	}
+
+	s.rules = guilds
+	return nil
+}
+
+// aclMutex serializes changes to ACL stores made by the built-in commands, as
+// they read and then write all of a guild's rules.
+var aclMutex sync.Mutex
+
+// updateACL replaces the guild's rules with what update returns.
+func updateACL(store ACLStore, guildID string,
+	update func([]ACLRule) ([]ACLRule, error)) error {
+
+	aclMutex.Lock()
+	defer aclMutex.Unlock()
+
+	rules, err := store.Rules(guildID)
+	if err != nil {
+		return errors.Wrap(err, "Failed to get rules")
+	}
+
+	rules, err = update(rules)
+	if err != nil {
+		return err
+	}
+
+	if err := store.SetRules(guildID, rules); err != nil {
+		return errors.Wrap(err, "Failed to save rules")
+	}
+
+	return nil
+}
+
+// aclManager is implemented by the built-in subcommands that manage the ACL
+// rules, which the rules don't apply to.
+type aclManager interface {
+	manageACL()
+}
+
+// ACLCommands is a subcommand named "acl" that manages the guild's ACL rules.
+// Context's ACL should be set to the same store. Using it requires the Manage
+// Server permission.
+//
+//    ctx.ACL = store
+//    ctx.RegisterSubcommand(&rfrouter.ACLCommands{Store: store})
+//
+// Rules are added with a role, user or channel mention and an optional
+// command path, e.g. "~acl allow @DJ music" or "~acl deny #general".
+type ACLCommands struct {
+	Context *Context
+	Store   ACLStore
+}
+
+func (a *ACLCommands) Name() string {
+	return "acl"
+}
+
+func (a *ACLCommands) RequiredPermissions() int {
+	return discordgo.PermissionManageServer
+}
+
+func (a *ACLCommands) manageACL() {}
+
+func (a *ACLCommands) Description() string {
+	return "allow or deny roles, users and channels the use of commands"
+}
+
+func (a *ACLCommands) CommandDescriptions() map[string]string {
+	return map[string]string{
+		"list":   "list the rules",
+		"allow":  "allow a role, user or channel to use a command",
+		"deny":   "deny a role, user or channel the use of a command",
+		"remove": "remove a rule by its number in the list",
+	}
+}
+
+func (a *ACLCommands) CommandExamples() map[string][]string {
+	return map[string][]string{
+		"allow":  {"@DJ music", "#bot-commands"},
+		"deny":   {"@user", "#general music play"},
+		"remove": {"2"},
+	}
+}
+
+// List replies with the guild's rules, numbered for Remove.
+func (a *ACLCommands) List(m *discordgo.MessageCreate) error {
+	rules, err := a.Store.Rules(m.GuildID)
+	if err != nil {
+		return errors.Wrap(err, "Failed to get rules")
+	}
+
+	if len(rules) == 0 {
+		return a.Context.Send(m.ChannelID, "There are no rules.")
+	}
+
+	var list strings.Builder
+	for i, rule := range rules {
+		list.WriteString(strconv.Itoa(i+1) + ". " + rule.String() + "\n")
+	}
+
+	return a.Context.Send(m.ChannelID, list.String())
+}
+
+// Allow adds a rule allowing the mentioned role, user or channel.
+func (a *ACLCommands) Allow(m *discordgo.MessageCreate,
+	target string, command ...string) error {
+
+	return a.add(m, true, target, command)
+}
+
+// Deny adds a rule denying the mentioned role, user or channel.
+func (a *ACLCommands) Deny(m *discordgo.MessageCreate,
+	target string, command ...string) error {
+
+	return a.add(m, false, target, command)
+}
+
+// Remove removes the rule with the number shown by List.
+func (a *ACLCommands) Remove(m *discordgo.MessageCreate, n int) error {
+	var rule ACLRule
+
+	err := updateACL(a.Store, m.GuildID, func(rules []ACLRule) ([]ACLRule, error) {
+		if n < 1 || n > len(rules) {
+			return nil, errors.New("There's no rule " + strconv.Itoa(n))
+		}
+
+		rule = rules[n-1]
+		return append(rules[:n-1], rules[n:]...), nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return a.Context.Send(m.ChannelID, "Removed rule: "+rule.String())
+}
+
+func (a *ACLCommands) add(m *discordgo.MessageCreate,
+	allow bool, target string, command []string) error {
+
+	t, id, err := parseACLTarget(target)
+	if err != nil {
+		return err
+	}
+
+	path, err := a.Context.commandPath(command)
+	if err != nil {
+		return err
+	}
+
+	var rule = ACLRule{Command: path, Target: t, ID: id, Allow: allow}
+
+	err = updateACL(a.Store, m.GuildID, func(rules []ACLRule) ([]ACLRule, error) {
+		return append(rules, rule), nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return a.Context.Send(m.ChannelID, "Added rule: "+rule.String())
+}
+
+// parseACLTarget parses a role, user or channel mention.
+func parseACLTarget(mention string) (ACLTarget, string, error) {
+	if !strings.HasPrefix(mention, "<") || !strings.HasSuffix(mention, ">") {
+		return "", "", errors.New("Not a role, user or channel mention: " + mention)
+	}
+
+	var id = mention[1 : len(mention)-1]
+	var target ACLTarget
+
+	switch {
+	case strings.HasPrefix(id, "@&"):
+		target, id = ACLRole, id[2:]
+	case strings.HasPrefix(id, "@!"):
+		target, id = ACLUser, id[2:]
+	case strings.HasPrefix(id, "@"):
+		target, id = ACLUser, id[1:]
+	case strings.HasPrefix(id, "#"):
+		target, id = ACLChannel, id[1:]
+	default:
+		return "", "", errors.New("Not a role, user or channel mention: " + mention)
+	}
+
+	if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+		return "", "", errors.New("Not a role, user or channel mention: " + mention)
+	}
+
+	return target, id, nil
+}
+
+// commandPath resolves the words, which may be names or aliases, into the path
+// of a command or subcommand.
+func (ctx *Context) commandPath(words []string) (string, error) {
+	cmd, sub, unknown := ctx.findPath(words)
+	if unknown >= 0 && cmd == nil {
+		return "", errors.New("Unknown command: " + strings.Join(words[:unknown+1], " "))
+	}
+
+	var path = sub.Path()
+	if cmd != nil {
+		path = append(path, cmd.name)
+	}
+
+	if unknown >= 0 {
+		return "", errors.New("Too many words after command: " + strings.Join(path, " "))
+	}
+
+	return strings.Join(path, " "), nil
+}
@@ -0,0 +1,452 @@
+package rfrouter
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type aclCommands struct {
+	Ctx *Context
+}
+
+func (a *aclCommands) Ping(_ *discordgo.MessageCreate) error {
+	return nil
+}
+
+type aclEvents struct {
+	Ctx    *Context
+	Events []string
+}
+
+func (a *aclEvents) OnMemberAdd(_ *discordgo.GuildMemberAdd) error {
+	a.Events = append(a.Events, "member add")
+	return nil
+}
+
+func (a *aclEvents) OnTyping(_ *discordgo.TypingStart) error {
+	a.Events = append(a.Events, "typing")
+	return nil
+}
+
+type musicCommands struct {
+	Ctx *Context
+}
+
+func (m *musicCommands) Name() string {
+	return "music"
+}
+
+func (m *musicCommands) Aliases() []string {
+	return []string{"m"}
+}
+
+func (m *musicCommands) Play(_ *discordgo.MessageCreate) error {
+	return nil
+}
+
+func (m *musicCommands) Skip(_ *discordgo.MessageCreate) error {
+	return nil
+}
+
+func TestACL(t *testing.T) {
+	ctx, err := New(newTestSession(t), &aclCommands{})
+	if err != nil {
+		t.Fatal("Failed to create new context:", err)
+	}
+
+	if _, err := ctx.RegisterSubcommand(&musicCommands{}); err != nil {
+		t.Fatal("Failed to register subcommand:", err)
+	}
+
+	var store = NewMemoryACLStore()
+	ctx.ACL = store
+
+	var call = func(content, userID, channelID string) error {
+		return ctx.Call(&discordgo.MessageCreate{
+			Message: &discordgo.Message{
+				Content:   content,
+				GuildID:   "guild",
+				ChannelID: channelID,
+				Author:    &discordgo.User{ID: userID},
+			},
+		})
+	}
+
+	type check struct {
+		content, userID, channelID string
+		allowed                    bool
+	}
+
+	var tests = []struct {
+		name   string
+		rules  []ACLRule
+		checks []check
+	}{{
+		name:  "no rules",
+		rules: nil,
+		checks: []check{
+			{"~music play", "user", "sfw", true},
+		},
+	}, {
+		name: "allow role",
+		rules: []ACLRule{
+			{Command: "music", Target: ACLRole, ID: "mod", Allow: true},
+		},
+		checks: []check{
+			{"~music play", "mod", "sfw", true},
+			{"~music play", "user", "sfw", false},
+			{"~music play", "admin", "sfw", true},
+			{"~ping", "user", "sfw", true},
+		},
+	}, {
+		name: "deny user",
+		rules: []ACLRule{
+			{Command: "music", Target: ACLRole, ID: "mod", Allow: true},
+			{Command: "music skip", Target: ACLUser, ID: "mod"},
+		},
+		checks: []check{
+			{"~music play", "mod", "sfw", true},
+			{"~music skip", "mod", "sfw", false},
+		},
+	}, {
+		name: "more specific allow",
+		rules: []ACLRule{
+			{Target: ACLUser, ID: "user"},
+			{Command: "ping", Target: ACLUser, ID: "user", Allow: true},
+		},
+		checks: []check{
+			{"~ping", "user", "sfw", true},
+			{"~music play", "user", "sfw", false},
+			{"~music play", "mod", "sfw", true},
+		},
+	}, {
+		name: "deny over allow",
+		rules: []ACLRule{
+			{Command: "music", Target: ACLRole, ID: "mod", Allow: true},
+			{Command: "music", Target: ACLUser, ID: "mod"},
+		},
+		checks: []check{
+			{"~music play", "mod", "sfw", false},
+		},
+	}, {
+		name: "channels",
+		rules: []ACLRule{
+			{Target: ACLChannel, ID: "sfw", Allow: true},
+			{Command: "music", Target: ACLRole, ID: "mod", Allow: true},
+		},
+		checks: []check{
+			{"~ping", "user", "sfw", true},
+			{"~ping", "user", "nsfw", false},
+			{"~music play", "mod", "sfw", true},
+			{"~music play", "mod", "nsfw", false},
+			{"~music play", "user", "sfw", false},
+			{"~ping", "admin", "nsfw", true},
+		},
+	}}
+
+	for _, test := range tests {
+		if err := store.SetRules("guild", test.rules); err != nil {
+			t.Fatal("Failed to set rules:", err)
+		}
+
+		for _, check := range test.checks {
+			err := call(check.content, check.userID, check.channelID)
+
+			if check.allowed {
+				if err != nil {
+					t.Fatal(test.name+": unexpected error for", check, err)
+				}
+				continue
+			}
+
+			switch err.(type) {
+			case *ErrACLDenied, *ErrChannelRestricted:
+			default:
+				t.Fatal(test.name+": expected ACL error for", check, "got", err)
+			}
+		}
+	}
+
+	t.Run("DM", func(t *testing.T) {
+		err := ctx.Call(&discordgo.MessageCreate{
+			Message: &discordgo.Message{
+				Content:   "~ping",
+				ChannelID: "dm",
+				Author:    &discordgo.User{ID: "user"},
+			},
+		})
+
+		if err != nil {
+			t.Fatal("unexpected error in DMs:", err)
+		}
+	})
+
+	t.Run("commands", func(t *testing.T) {
+		sub, err := ctx.RegisterSubcommand(&ACLCommands{Store: store})
+		if err != nil {
+			t.Fatal("Failed to register ACL commands:", err)
+		}
+
+		if sub.Name() != "acl" {
+			t.Fatal("unexpected ACL subcommand:", sub.Name())
+		}
+
+		missing, ok := call("~acl list", "mod", "sfw").(*ErrMissingPermissions)
+		if !ok || missing.Bot || missing.Missing != discordgo.PermissionManageServer {
+			t.Fatal("expected ACL commands to require Manage Server, got", missing)
+		}
+
+		// The rules don't apply to the commands changing them.
+		if _, ok := call("~acl list", "mod", "nsfw").(*ErrMissingPermissions); !ok {
+			t.Fatal("expected ACL commands to ignore the rules")
+		}
+	})
+
+	t.Run("command path", func(t *testing.T) {
+		for words, expect := range map[string]string{
+			"":            "",
+			"ping":        "ping",
+			"m":           "music",
+			"music skip":  "music skip",
+			"m play":      "music play",
+			"music pause": "",
+			"ping pong":   "",
+		} {
+			path, err := ctx.commandPath(splitWords(words))
+			if path != expect || (expect == "" && words != "" && err == nil) {
+				t.Fatal("unexpected path for", words, path, err)
+			}
+		}
+
+		_, err := ctx.commandPath([]string{"music", "play", "extra"})
+		if err == nil || err.Error() != "Too many words after command: music play" {
+			t.Fatal("unexpected error:", err)
+		}
+	})
+}
+
+func TestACLEvents(t *testing.T) {
+	var events = &aclEvents{}
+
+	ctx, err := New(newTestSession(t), events)
+	if err != nil {
+		t.Fatal("Failed to create new context:", err)
+	}
+
+	var store = NewMemoryACLStore()
+	ctx.ACL = store
+
+	err = store.SetRules("guild", []ACLRule{
+		{Target: ACLRole, ID: "mod", Allow: true},
+		{Target: ACLChannel, ID: "sfw", Allow: true},
+	})
+	if err != nil {
+		t.Fatal("Failed to set rules:", err)
+	}
+
+	// Events are handled regardless of who caused them, and anywhere without a
+	// channel.
+	err = ctx.Call(&discordgo.GuildMemberAdd{
+		Member: &discordgo.Member{
+			GuildID: "guild",
+			User:    &discordgo.User{ID: "user"},
+		},
+	})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = ctx.Call(&discordgo.TypingStart{
+		GuildID:   "guild",
+		ChannelID: "sfw",
+		UserID:    "user",
+	})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// Events in channels follow the channel rules.
+	err = ctx.Call(&discordgo.TypingStart{
+		GuildID:   "guild",
+		ChannelID: "nsfw",
+		UserID:    "mod",
+	})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if !reflect.DeepEqual(events.Events, []string{"member add", "typing"}) {
+		t.Fatal("unexpected events:", events.Events)
+	}
+}
+
+func TestChannelRestrictions(t *testing.T) {
+	ctx, err := New(newTestSession(t), &aclCommands{})
+	if err != nil {
+		t.Fatal("Failed to create new context:", err)
+	}
+
+	if _, err := ctx.RegisterSubcommand(&musicCommands{}); err != nil {
+		t.Fatal("Failed to register subcommand:", err)
+	}
+
+	var store = NewMemoryACLStore()
+	ctx.ACL = store
+
+	err = store.SetRules("guild", []ACLRule{
+		{Target: ACLChannel, ID: "sfw", Allow: true},
+		{Command: "music", Target: ACLChannel, ID: "nsfw", Allow: true},
+		{Command: "music skip", Target: ACLChannel, ID: "nsfw"},
+	})
+	if err != nil {
+		t.Fatal("Failed to set rules:", err)
+	}
+
+	var call = func(content, channelID string) error {
+		return ctx.Call(&discordgo.MessageCreate{
+			Message: &discordgo.Message{
+				Content:   content,
+				GuildID:   "guild",
+				ChannelID: channelID,
+				Author:    &discordgo.User{ID: "user"},
+			},
+		})
+	}
+
+	var tests = []struct {
+		content, channelID string
+		allowed            bool
+	}{
+		{"~ping", "sfw", true},
+		{"~ping", "nsfw", false},
+		{"~music play", "nsfw", true},
+		{"~music play", "sfw", false},
+		{"~music skip", "nsfw", false},
+	}
+
+	for _, test := range tests {
+		err := call(test.content, test.channelID)
+
+		if test.allowed {
+			if err != nil {
+				t.Fatal("unexpected error for", test, err)
+			}
+			continue
+		}
+
+		restricted, ok := err.(*ErrChannelRestricted)
+		if !ok {
+			t.Fatal("expected channel restricted error for", test, "got", err)
+		}
+
+		if restricted.ChannelID != test.channelID {
+			t.Fatal("unexpected channel:", restricted.ChannelID)
+		}
+	}
+
+	t.Run("ignore", func(t *testing.T) {
+		ctx.IgnoreRestrictedChannels = true
+
+		if err := call("~ping", "nsfw"); err != nil {
+			t.Fatal("expected restricted command to be ignored, got", err)
+		}
+	})
+
+	t.Run("commands", func(t *testing.T) {
+		sub, err := ctx.RegisterSubcommand(&ChannelCommands{Store: store})
+		if err != nil {
+			t.Fatal("Failed to register channel commands:", err)
+		}
+
+		if sub.Name() != "channels" || sub.Permissions != discordgo.PermissionManageServer {
+			t.Fatal("unexpected channel subcommand:", sub.Name(), sub.Permissions)
+		}
+
+		// The rules don't apply to the commands changing them.
+		if _, ok := call("~channels list", "nsfw").(*ErrMissingPermissions); !ok {
+			t.Fatal("expected channel commands to ignore the rules")
+		}
+	})
+}
+
+func splitWords(s string) []string {
+	tokens, _ := ParseArgs(s)
+	return tokenValues(tokens)
+}
+
+func TestParseACLTarget(t *testing.T) {
+	var tests = []struct {
+		mention string
+		target  ACLTarget
+		id      string
+	}{
+		{"<@&123>", ACLRole, "123"},
+		{"<@123>", ACLUser, "123"},
+		{"<@!123>", ACLUser, "123"},
+		{"<#123>", ACLChannel, "123"},
+		{"<:emoji:123>", "", ""},
+		{"<@abc>", "", ""},
+		{"123", "", ""},
+	}
+
+	for _, test := range tests {
+		target, id, err := parseACLTarget(test.mention)
+		if target != test.target || id != test.id || (test.id == "") != (err != nil) {
+			t.Fatal("unexpected result for", test.mention, target, id, err)
+		}
+	}
+}
+
+func TestJSONACLStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rfrouter")
+	if err != nil {
+		t.Fatal("Failed to create temporary directory:", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var path = filepath.Join(dir, "acl.json")
+	var rules = []ACLRule{
+		{Command: "music", Target: ACLRole, ID: "1", Allow: true},
+		{Target: ACLChannel, ID: "2"},
+	}
+
+	s, err := NewJSONACLStore(path)
+	if err != nil {
+		t.Fatal("Failed to create store:", err)
+	}
+
+	if err := s.SetRules("guild", rules); err != nil {
+		t.Fatal("Failed to set rules:", err)
+	}
+
+	s, err = NewJSONACLStore(path)
+	if err != nil {
+		t.Fatal("Failed to load store:", err)
+	}
+
+	if r, _ := s.Rules("guild"); !reflect.DeepEqual(r, rules) {
+		t.Fatal("unexpected rules:", r)
+	}
+
+	// Rules that fail to save aren't set.
+	if err := os.Remove(path); err != nil {
+		t.Fatal("Failed to remove file:", err)
+	}
+
+	if err := os.Mkdir(path, 0755); err != nil {
+		t.Fatal("Failed to create directory:", err)
+	}
+
+	if err := s.SetRules("guild", nil); err == nil {
+		t.Fatal("expected an error saving into a directory")
+	}
+
+	if r, _ := s.Rules("guild"); !reflect.DeepEqual(r, rules) {
+		t.Fatal("unexpected rules after failing to save:", r)
+	}
+}
@@ -23,6 +23,19 @@ type ManualParseable interface {
 	ParseContent([]string) error
 }
 
+// Optional marks a Parseable argument type as optional. Optional arguments can
+// only be followed by other optional arguments, and they will be nil if
+// omitted. Pointers to non-Parseable types, such as *int, are always optional.
+type Optional interface {
+	Optional()
+}
+
+// Remaining is an argument type that takes the rest of the message content
+// after the arguments before it, untouched. This is useful for free-form text
+// such as reasons, as the spacing and quotes are kept. If used, this must be
+// the last argument. It is empty if nothing is left.
+type Remaining string
+
 type RawArguments struct {
 	Arguments []string
 }
@@ -60,9 +73,27 @@ func getArgumentValueFn(t reflect.Type) (argumentValueFn, error) {
 	var fn argumentValueFn
 
 	switch t.Kind() {
+	case reflect.Ptr:
+		elemFn, err := getArgumentValueFn(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+
+		fn = func(s string) (reflect.Value, error) {
+			v, err := elemFn(s)
+			if err != nil {
+				return nilV, err
+			}
+
+			ptr := reflect.New(t.Elem())
+			ptr.Elem().Set(v)
+
+			return ptr, nil
+		}
+
 	case reflect.String:
 		fn = func(s string) (reflect.Value, error) {
-			return reflect.ValueOf(s), nil
+			return quickRet(s, nil, t)
 		}
 
 	case reflect.Int, reflect.Int8,
@@ -120,3 +151,12 @@ func quickRet(v interface{}, err error, t reflect.Type) (reflect.Value, error) {
 
 	return rv.Convert(t), nil
 }
+
+// isOptional returns true if the argument type may be omitted.
+func isOptional(t reflect.Type) bool {
+	if t.Implements(typeIOptional) {
+		return true
+	}
+
+	return t.Kind() == reflect.Ptr && !t.Implements(typeIParser)
+}
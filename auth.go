@@ -0,0 +1,129 @@
+package rfrouter
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"github.com/pkg/errors"
+)
+
+// RoleRequirer is optionally used to set the roles required for all commands
+// in a subcommand. The user needs at least one of the role IDs.
+type RoleRequirer interface {
+	RequiredRoles() []string
+}
+
+// CommandRoler is optionally used to set the roles required for commands. The
+// returned map's keys are command names, such as "play", and the user needs at
+// least one of each command's role IDs.
+type CommandRoler interface {
+	CommandRoles() map[string][]string
+}
+
+// ErrForbidden is returned when the user isn't allowed to use a command.
+type ErrForbidden struct {
+	Command *CommandContext
+
+	// Flag is the flag that the user doesn't satisfy, such as OwnerOnly, or
+	// None if the user is missing a role.
+	Flag NameFlag
+	// Roles are the role IDs that the user needs one of, if any.
+	Roles []string
+}
+
+func (err *ErrForbidden) Error() string {
+	return "You are not allowed to use this command."
+}
+
+// authorize checks that the event's user may use the command where the event
+// happened. All dispatch paths go through this before invoking a command.
+//
+// isAdmin caches whether the user is an administrator across the commands
+// checked for a single event, and should point to a nil *bool at first.
+func (ctx *Context) authorize(cmd *CommandContext, ev interface{}, isAdmin **bool) error {
+	if err := ctx.checkOwner(cmd, ev); err != nil {
+		return err
+	}
+
+	if err := ctx.checkAdmin(cmd, ev, isAdmin); err != nil {
+		return err
+	}
+
+	if err := ctx.checkFlags(cmd, ev); err != nil {
+		return err
+	}
+
+	if err := ctx.checkRoles(cmd, ev, isAdmin); err != nil {
+		return err
+	}
+
+	if err := ctx.checkACL(cmd, ev, isAdmin); err != nil {
+		return err
+	}
+
+	return ctx.checkPermissions(cmd, ev)
+}
+
+// checkAdmin checks that the user is a guild administrator if the command or
+// any of its subcommands is admin-only. Nobody is an administrator outside of
+// guilds.
+func (ctx *Context) checkAdmin(cmd *CommandContext, ev interface{}, isAdmin **bool) error {
+	if !cmd.flags().Is(AdminOnly) {
+		return nil
+	}
+
+	if reflectGuildID(ev) == "" || !ctx.eventIsAdmin(ev, isAdmin) {
+		return &ErrForbidden{Command: cmd, Flag: AdminOnly}
+	}
+
+	return nil
+}
+
+// checkRoles checks that the user has one of the roles required by the command
+// and by each of its subcommands. Administrators are always allowed.
+func (ctx *Context) checkRoles(cmd *CommandContext, ev interface{}, isAdmin **bool) error {
+	var required = [][]string{cmd.Roles}
+	for sub := cmd.parent; sub != nil; sub = sub.parent {
+		required = append(required, sub.Roles)
+	}
+
+	var member *discordgo.Member
+
+	for _, roles := range required {
+		if len(roles) == 0 {
+			continue
+		}
+
+		if member == nil {
+			var guildID = reflectGuildID(ev)
+			if guildID == "" {
+				return &ErrGuildOnly{cmd}
+			}
+
+			if ctx.eventIsAdmin(ev, isAdmin) {
+				return nil
+			}
+
+			m, err := ctx.Member(guildID, reflectUserID(ev))
+			if err != nil {
+				return errors.Wrap(err, "Failed to get member")
+			}
+
+			member = m
+		}
+
+		if !hasAnyRole(member, roles) {
+			return &ErrForbidden{Command: cmd, Flag: None, Roles: roles}
+		}
+	}
+
+	return nil
+}
+
+func hasAnyRole(member *discordgo.Member, roles []string) bool {
+	for _, role := range member.Roles {
+		if contains(roles, role) {
+			return true
+		}
+	}
+
+	return false
+}
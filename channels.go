@@ -0,0 +1,155 @@
+package rfrouter
+
+import (
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/pkg/errors"
+)
+
+// ChannelCommands is a subcommand named "channels" that restricts where
+// commands can be used, with the channel rules of the guild's ACL. Context's
+// ACL should be set to the same store. Using it requires the Manage Server
+// permission.
+//
+//    ctx.ACL = store
+//    ctx.RegisterSubcommand(&rfrouter.ChannelCommands{Store: store})
+//
+// Restrictions apply to the whole bot, or to a subcommand or a command given
+// after the channel, e.g. "~channels allow #bot-commands" or
+// "~channels deny #general music".
+type ChannelCommands struct {
+	Context *Context
+	Store   ACLStore
+}
+
+func (c *ChannelCommands) Name() string {
+	return "channels"
+}
+
+func (c *ChannelCommands) RequiredPermissions() int {
+	return discordgo.PermissionManageServer
+}
+
+func (c *ChannelCommands) manageACL() {}
+
+func (c *ChannelCommands) Description() string {
+	return "restrict the channels commands can be used in"
+}
+
+func (c *ChannelCommands) CommandDescriptions() map[string]string {
+	return map[string]string{
+		"list":  "list the channel restrictions",
+		"allow": "only allow commands in the channel, and other allowed ones",
+		"deny":  "deny commands in the channel",
+		"clear": "remove the channel restrictions of commands",
+	}
+}
+
+func (c *ChannelCommands) CommandExamples() map[string][]string {
+	return map[string][]string{
+		"allow": {"#bot-commands", "#music music"},
+		"deny":  {"#general"},
+		"clear": {"music"},
+	}
+}
+
+// List replies with the guild's channel restrictions.
+func (c *ChannelCommands) List(m *discordgo.MessageCreate) error {
+	rules, err := c.Store.Rules(m.GuildID)
+	if err != nil {
+		return errors.Wrap(err, "Failed to get rules")
+	}
+
+	var list strings.Builder
+	for _, rule := range rules {
+		if rule.Target == ACLChannel {
+			list.WriteString(rule.String() + "\n")
+		}
+	}
+
+	if list.Len() == 0 {
+		return c.Context.Send(m.ChannelID, "Commands can be used in all channels.")
+	}
+
+	return c.Context.Send(m.ChannelID, list.String())
+}
+
+// Allow only allows the commands in the channel, along with any other allowed
+// channels.
+func (c *ChannelCommands) Allow(m *discordgo.MessageCreate,
+	channel string, command ...string) error {
+
+	return c.add(m, true, channel, command)
+}
+
+// Deny denies the commands in the channel.
+func (c *ChannelCommands) Deny(m *discordgo.MessageCreate,
+	channel string, command ...string) error {
+
+	return c.add(m, false, channel, command)
+}
+
+// Clear removes the channel restrictions of the commands.
+func (c *ChannelCommands) Clear(m *discordgo.MessageCreate, command ...string) error {
+	path, err := c.Context.commandPath(command)
+	if err != nil {
+		return err
+	}
+
+	err = updateACL(c.Store, m.GuildID, func(rules []ACLRule) ([]ACLRule, error) {
+		var kept = rules[:0]
+		for _, rule := range rules {
+			if rule.Target != ACLChannel || rule.Command != path {
+				kept = append(kept, rule)
+			}
+		}
+
+		return kept, nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if path == "" {
+		return c.Context.Send(m.ChannelID, "Cleared the channel restrictions.")
+	}
+
+	return c.Context.Send(m.ChannelID,
+		"Cleared the channel restrictions for "+path+".")
+}
+
+func (c *ChannelCommands) add(m *discordgo.MessageCreate,
+	allow bool, channel string, command []string) error {
+
+	target, id, err := parseACLTarget(channel)
+	if err != nil || target != ACLChannel {
+		return errors.New("Not a channel mention: " + channel)
+	}
+
+	path, err := c.Context.commandPath(command)
+	if err != nil {
+		return err
+	}
+
+	var rule = ACLRule{Command: path, Target: ACLChannel, ID: id, Allow: allow}
+
+	err = updateACL(c.Store, m.GuildID, func(rules []ACLRule) ([]ACLRule, error) {
+		// Replace any previous rule for the same channel and command.
+		var kept = rules[:0]
+		for _, r := range rules {
+			if r.Target != ACLChannel || r.ID != id || r.Command != path {
+				kept = append(kept, r)
+			}
+		}
+
+		return append(kept, rule), nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.Context.Send(m.ChannelID, "Added restriction: "+rule.String())
+}
@@ -0,0 +1,65 @@
+package rfrouter
+
+// ErrGuildOnly is returned when a guild-only command is used outside of a
+// guild.
+type ErrGuildOnly struct {
+	Command *CommandContext
+}
+
+func (err *ErrGuildOnly) Error() string {
+	return "This command can only be used in a server."
+}
+
+// ErrDMOnly is returned when a DM-only command is used in a guild.
+type ErrDMOnly struct {
+	Command *CommandContext
+}
+
+func (err *ErrDMOnly) Error() string {
+	return "This command can only be used in direct messages."
+}
+
+// ErrNSFWOnly is returned when an NSFW-only command is used in a guild channel
+// that isn't marked as NSFW. Direct messages are allowed.
+type ErrNSFWOnly struct {
+	Command *CommandContext
+}
+
+func (err *ErrNSFWOnly) Error() string {
+	return "This command can only be used in NSFW channels."
+}
+
+// flags returns the command's flags combined with those of its subcommands.
+func (cctx *CommandContext) flags() NameFlag {
+	var flag = cctx.Flag
+	for sub := cctx.parent; sub != nil; sub = sub.parent {
+		flag |= sub.Flag
+	}
+
+	return flag
+}
+
+// checkFlags checks that the command can be used where the event happened,
+// according to the GuildOnly, DMOnly and NSFWOnly flags of the command and its
+// subcommands.
+func (ctx *Context) checkFlags(cmd *CommandContext, ev interface{}) error {
+	var flag = cmd.flags()
+	var inGuild = reflectGuildID(ev) != ""
+
+	if flag.Is(GuildOnly) && !inGuild {
+		return &ErrGuildOnly{cmd}
+	}
+
+	if flag.Is(DMOnly) && inGuild {
+		return &ErrDMOnly{cmd}
+	}
+
+	if flag.Is(NSFWOnly) && inGuild {
+		c, err := ctx.Channel(reflectChannelID(ev))
+		if err != nil || !c.NSFW {
+			return &ErrNSFWOnly{cmd}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,265 @@
+package rfrouter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type flagCommands struct {
+	Ctx *Context
+}
+
+func (f *flagCommands) GーGuild(_ *discordgo.MessageCreate) error {
+	return nil
+}
+
+func (f *flagCommands) DーDM(_ *discordgo.MessageCreate) error {
+	return nil
+}
+
+func (f *flagCommands) NーNSFW(_ *discordgo.MessageCreate) error {
+	return nil
+}
+
+func (f *flagCommands) Ban(_ *discordgo.MessageCreate) error {
+	return nil
+}
+
+func (f *flagCommands) Purge(_ *discordgo.MessageCreate) error {
+	return nil
+}
+
+func (f *flagCommands) CommandPermissions() map[string]int {
+	return map[string]int{
+		"ban":   discordgo.PermissionBanMembers,
+		"purge": discordgo.PermissionManageMessages,
+	}
+}
+
+// newTestSession returns a session whose state has a guild "guild" containing a
+// "sfw" and an "nsfw" channel. The guild has the members "user" without any
+// roles, "mod" with Ban Members and Manage Messages, "admin" with
+// Administrator, and "bot" with only Ban Members, which is also the bot's user.
+func newTestSession(t *testing.T) *discordgo.Session {
+	var state = discordgo.NewState()
+	state.User = &discordgo.User{ID: "bot"}
+
+	var guild = &discordgo.Guild{
+		ID: "guild",
+		Roles: []*discordgo.Role{
+			{ID: "guild", Permissions: discordgo.PermissionSendMessages},
+			{ID: "mod", Permissions: discordgo.PermissionBanMembers |
+				discordgo.PermissionManageMessages},
+			{ID: "admin", Permissions: discordgo.PermissionAdministrator},
+			{ID: "bot", Permissions: discordgo.PermissionBanMembers},
+		},
+	}
+
+	if err := state.GuildAdd(guild); err != nil {
+		t.Fatal("Failed to add guild:", err)
+	}
+
+	for _, ch := range []*discordgo.Channel{
+		{ID: "sfw", GuildID: "guild"},
+		{ID: "nsfw", GuildID: "guild", NSFW: true},
+	} {
+		if err := state.ChannelAdd(ch); err != nil {
+			t.Fatal("Failed to add channel:", err)
+		}
+	}
+
+	for id, roles := range map[string][]string{
+		"user":  nil,
+		"mod":   {"mod"},
+		"admin": {"admin"},
+		"bot":   {"bot"},
+	} {
+		err := state.MemberAdd(&discordgo.Member{
+			GuildID: "guild",
+			User:    &discordgo.User{ID: id},
+			Roles:   roles,
+		})
+		if err != nil {
+			t.Fatal("Failed to add member:", err)
+		}
+	}
+
+	return &discordgo.Session{State: state}
+}
+
+func TestCheckFlags(t *testing.T) {
+	ctx, err := New(newTestSession(t), &flagCommands{})
+	if err != nil {
+		t.Fatal("Failed to create new context:", err)
+	}
+
+	var call = func(content, guildID, channelID string) error {
+		return ctx.Call(&discordgo.MessageCreate{
+			Message: &discordgo.Message{
+				Content:   content,
+				GuildID:   guildID,
+				ChannelID: channelID,
+			},
+		})
+	}
+
+	t.Run("guild only", func(t *testing.T) {
+		if err := call("~guild", "guild", "sfw"); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if _, ok := call("~guild", "", "dm").(*ErrGuildOnly); !ok {
+			t.Fatal("expected guild-only error")
+		}
+	})
+
+	t.Run("DM only", func(t *testing.T) {
+		if err := call("~dm", "", "dm"); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if _, ok := call("~dm", "guild", "sfw").(*ErrDMOnly); !ok {
+			t.Fatal("expected DM-only error")
+		}
+	})
+
+	t.Run("NSFW only", func(t *testing.T) {
+		for _, channelID := range []string{"nsfw", "dm"} {
+			var guildID = "guild"
+			if channelID == "dm" {
+				guildID = ""
+			}
+
+			if err := call("~nsfw", guildID, channelID); err != nil {
+				t.Fatal("unexpected error in", channelID, err)
+			}
+		}
+
+		if _, ok := call("~nsfw", "guild", "sfw").(*ErrNSFWOnly); !ok {
+			t.Fatal("expected NSFW-only error")
+		}
+	})
+
+	t.Run("help", func(t *testing.T) {
+		var inGuild = ctx.HelpFor(&discordgo.MessageCreate{
+			Message: &discordgo.Message{GuildID: "guild"},
+		})
+
+		if strings.Contains(inGuild, "~dm") || !strings.Contains(inGuild, "~guild") {
+			t.Fatal("unexpected help in guild:\n" + inGuild)
+		}
+
+		var inDM = ctx.HelpFor(&discordgo.MessageCreate{
+			Message: &discordgo.Message{},
+		})
+
+		if !strings.Contains(inDM, "~dm") || strings.Contains(inDM, "~guild") {
+			t.Fatal("unexpected help in DM:\n" + inDM)
+		}
+
+		if !strings.Contains(ctx.Help(), "~guild (server only)") {
+			t.Fatal("guild-only command not annotated:\n" + ctx.Help())
+		}
+	})
+}
+
+func TestCheckPermissions(t *testing.T) {
+	ctx, err := New(newTestSession(t), &flagCommands{})
+	if err != nil {
+		t.Fatal("Failed to create new context:", err)
+	}
+
+	var call = func(content, userID string) error {
+		return ctx.Call(&discordgo.MessageCreate{
+			Message: &discordgo.Message{
+				Content:   content,
+				GuildID:   "guild",
+				ChannelID: "sfw",
+				Author:    &discordgo.User{ID: userID},
+			},
+		})
+	}
+
+	for _, userID := range []string{"mod", "admin"} {
+		if err := call("~ban", userID); err != nil {
+			t.Fatal("unexpected error for", userID, err)
+		}
+	}
+
+	missing, ok := call("~ban", "user").(*ErrMissingPermissions)
+	if !ok {
+		t.Fatal("expected missing permissions error")
+	}
+
+	if missing.Bot || missing.Missing != discordgo.PermissionBanMembers {
+		t.Fatal("unexpected missing permissions:", missing)
+	}
+
+	if s := missing.Error(); s != "You are missing the following permissions: Ban Members." {
+		t.Fatal("unexpected error:", s)
+	}
+
+	missing, ok = call("~purge", "mod").(*ErrMissingPermissions)
+	if !ok || !missing.Bot {
+		t.Fatal("expected the bot to be missing permissions, got", missing)
+	}
+}
+
+type OーOwnerCommands struct {
+	Ctx *Context
+}
+
+func (o *OーOwnerCommands) Die(_ *discordgo.MessageCreate) error {
+	return nil
+}
+
+func (f *flagCommands) OーSecret(_ *discordgo.MessageCreate) error {
+	return nil
+}
+
+func TestCheckOwner(t *testing.T) {
+	ctx, err := New(newTestSession(t), &flagCommands{})
+	if err != nil {
+		t.Fatal("Failed to create new context:", err)
+	}
+
+	ctx.Owners = []string{"owner"}
+
+	if _, err := ctx.RegisterSubcommand(&OーOwnerCommands{}); err != nil {
+		t.Fatal("Failed to register subcommand:", err)
+	}
+
+	var message = func(content, userID string) *discordgo.MessageCreate {
+		return &discordgo.MessageCreate{
+			Message: &discordgo.Message{
+				Content: content,
+				Author:  &discordgo.User{ID: userID},
+			},
+		}
+	}
+
+	for _, content := range []string{"~secret", "~ownercommands die"} {
+		if err := ctx.Call(message(content, "owner")); err != nil {
+			t.Fatal("unexpected error for owner:", err)
+		}
+
+		forbidden, ok := ctx.Call(message(content, "admin")).(*ErrForbidden)
+		if !ok || forbidden.Flag != OwnerOnly {
+			t.Fatal("expected forbidden error for", content)
+		}
+	}
+
+	if help := ctx.HelpFor(message("", "admin")); strings.Contains(help, "secret") ||
+		strings.Contains(help, "ownercommands") {
+
+		t.Fatal("owner-only commands shown to non-owners:\n" + help)
+	}
+
+	if help := ctx.HelpFor(message("", "owner")); !strings.Contains(help, "secret") ||
+		!strings.Contains(help, "ownercommands") {
+
+		t.Fatal("owner-only commands hidden from owners:\n" + help)
+	}
+}
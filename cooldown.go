@@ -0,0 +1,163 @@
+package rfrouter
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CooldownBucket decides which invocations of a command share a cooldown.
+type CooldownBucket uint8
+
+const (
+	// BucketUser limits each user separately.
+	BucketUser CooldownBucket = iota
+	// BucketChannel limits each channel separately.
+	BucketChannel
+	// BucketGuild limits each guild separately.
+	BucketGuild
+	// BucketGlobal limits everyone together.
+	BucketGlobal
+)
+
+// Cooldown allows a command to be used Uses times every Per duration in the
+// same bucket, e.g. 2 uses per minute per channel.
+type Cooldown struct {
+	Bucket CooldownBucket
+	Uses   int
+	Per    time.Duration
+}
+
+// CommandCooldowner is optionally used to set the cooldowns of commands. The
+// returned map's keys are command names, such as "roll".
+type CommandCooldowner interface {
+	CommandCooldowns() map[string]Cooldown
+}
+
+// CooldownStore keeps track of the uses in each cooldown bucket.
+type CooldownStore interface {
+	// Take uses up one use in the bucket with the given key. If there are no
+	// uses left, no use is taken and the time until the bucket resets is
+	// returned instead of 0.
+	Take(key string, cooldown Cooldown) time.Duration
+}
+
+// ErrCooldown is returned when a command is used while on cooldown.
+type ErrCooldown struct {
+	Command  *CommandContext
+	Cooldown Cooldown
+
+	// Remaining is the time until the command can be used again.
+	Remaining time.Duration
+}
+
+func (err *ErrCooldown) Error() string {
+	// Round up, so "0s" is never shown.
+	var remaining = (err.Remaining + time.Second - 1).Truncate(time.Second)
+	return "This command is on cooldown, try again in " + remaining.String() + "."
+}
+
+// checkCooldown takes a use from the command's cooldown bucket for the event,
+// returning an *ErrCooldown if there are no uses left.
+func (ctx *Context) checkCooldown(cmd *CommandContext, ev interface{}) error {
+	if cmd.Cooldown == nil || ctx.Cooldowns == nil {
+		return nil
+	}
+
+	var key = append(cmd.parent.Path(), cmd.name)
+
+	switch cmd.Cooldown.Bucket {
+	case BucketUser:
+		key = append(key, "user", reflectUserID(ev))
+	case BucketChannel:
+		key = append(key, "channel", reflectChannelID(ev))
+	case BucketGuild:
+		key = append(key, "guild", reflectGuildID(ev))
+	case BucketGlobal:
+		key = append(key, "global")
+	default:
+		return errors.New("Unknown cooldown bucket for " + cmd.name)
+	}
+
+	// NUL can't be in a command name or an ID, so it can't collide.
+	remaining := ctx.Cooldowns.Take(strings.Join(key, "\x00"), *cmd.Cooldown)
+	if remaining <= 0 {
+		return nil
+	}
+
+	return &ErrCooldown{
+		Command:   cmd,
+		Cooldown:  *cmd.Cooldown,
+		Remaining: remaining,
+	}
+}
+
+// MemoryCooldownStore is a CooldownStore that keeps buckets in memory. Expired
+// buckets are cleaned up once in a while. It is safe for concurrent use.
+type MemoryCooldownStore struct {
+	mutex   sync.Mutex
+	buckets map[string]*cooldownBucket
+
+	// number of buckets before the next cleanup
+	nextSweep int
+	// overridable in tests
+	now func() time.Time
+}
+
+type cooldownBucket struct {
+	reset time.Time
+	uses  int
+}
+
+// minSweep is the minimum number of buckets before expired ones are removed.
+const minSweep = 128
+
+var _ CooldownStore = (*MemoryCooldownStore)(nil)
+
+func NewMemoryCooldownStore() *MemoryCooldownStore {
+	return &MemoryCooldownStore{
+		buckets:   map[string]*cooldownBucket{},
+		nextSweep: minSweep,
+		now:       time.Now,
+	}
+}
+
+func (s *MemoryCooldownStore) Take(key string, cooldown Cooldown) time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var now = s.now()
+
+	b, ok := s.buckets[key]
+	if !ok || !now.Before(b.reset) {
+		b = &cooldownBucket{reset: now.Add(cooldown.Per)}
+		s.buckets[key] = b
+
+		if len(s.buckets) >= s.nextSweep {
+			s.sweep(now)
+		}
+	}
+
+	if b.uses >= cooldown.Uses {
+		return b.reset.Sub(now)
+	}
+
+	b.uses++
+	return 0
+}
+
+// sweep removes all expired buckets. The mutex must be held.
+func (s *MemoryCooldownStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if !now.Before(b.reset) {
+			delete(s.buckets, key)
+		}
+	}
+
+	s.nextSweep = len(s.buckets) * 2
+	if s.nextSweep < minSweep {
+		s.nextSweep = minSweep
+	}
+}
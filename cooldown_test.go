@@ -0,0 +1,106 @@
+package rfrouter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type cooldownCommands struct {
+	Ctx *Context
+}
+
+func (c *cooldownCommands) Roll(_ *discordgo.MessageCreate) error {
+	return nil
+}
+
+func (c *cooldownCommands) CommandCooldowns() map[string]Cooldown {
+	return map[string]Cooldown{
+		"roll": {Bucket: BucketUser, Uses: 2, Per: time.Minute},
+	}
+}
+
+func TestMemoryCooldownStore(t *testing.T) {
+	var now = time.Now()
+	var cooldown = Cooldown{Uses: 2, Per: time.Second}
+
+	s := NewMemoryCooldownStore()
+	s.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if d := s.Take("a", cooldown); d != 0 {
+			t.Fatal("unexpected cooldown on use", i, d)
+		}
+	}
+
+	if d := s.Take("a", cooldown); d != time.Second {
+		t.Fatal("unexpected cooldown after all uses:", d)
+	}
+
+	if d := s.Take("b", cooldown); d != 0 {
+		t.Fatal("unexpected cooldown on another bucket:", d)
+	}
+
+	now = now.Add(time.Second)
+
+	if d := s.Take("a", cooldown); d != 0 {
+		t.Fatal("unexpected cooldown after reset:", d)
+	}
+
+	t.Run("sweep", func(t *testing.T) {
+		for i := 0; i < minSweep; i++ {
+			s.Take(string(rune('c'+i)), cooldown)
+		}
+
+		// Expire everything, then add enough buckets to trigger a sweep.
+		now = now.Add(time.Second)
+
+		for i, n := 0, s.nextSweep; i < n; i++ {
+			s.Take(string(rune('c'+minSweep+i)), cooldown)
+		}
+
+		for key := range s.buckets {
+			if key == "a" || key == "b" || key == "c" {
+				t.Fatal("expired bucket was not swept:", key)
+			}
+		}
+	})
+}
+
+func TestCooldown(t *testing.T) {
+	ctx, err := New(&discordgo.Session{}, &cooldownCommands{})
+	if err != nil {
+		t.Fatal("Failed to create new context:", err)
+	}
+
+	var call = func(userID string) error {
+		return ctx.Call(&discordgo.MessageCreate{
+			Message: &discordgo.Message{
+				Content: "~roll",
+				Author:  &discordgo.User{ID: userID},
+			},
+		})
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := call("1"); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	err = call("1")
+
+	cooldown, ok := err.(*ErrCooldown)
+	if !ok {
+		t.Fatal("expected cooldown error, got", err)
+	}
+
+	if cooldown.Remaining <= 0 || cooldown.Remaining > time.Minute {
+		t.Fatal("unexpected remaining duration:", cooldown.Remaining)
+	}
+
+	if err := call("2"); err != nil {
+		t.Fatal("unexpected error for another user:", err)
+	}
+}
@@ -1,10 +1,10 @@
 package rfrouter
 
 import (
-	"encoding/csv"
 	"log"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/pkg/errors"
@@ -20,9 +20,55 @@ type Context struct {
 	// Descriptive help body
 	Description string
 
-	// The prefix for commands
+	// The prefix for commands, used if PrefixFunc is nil
 	Prefix string
 
+	// PrefixFunc returns the prefixes that can be used for a message, such as
+	// the prefixes of its guild. If more than one prefix matches, the longest
+	// one is used. Empty prefixes are ignored.
+	PrefixFunc func(*discordgo.MessageCreate) []string
+
+	// MentionPrefix when true allows mentioning the bot in place of a prefix,
+	// e.g. "@Bot help".
+	MentionPrefix bool
+
+	// MentionReply returns the reply to a message that only mentions the bot,
+	// such as HelpFor. If nil, the bot replies with its prefixes. Returning an
+	// empty string means ignoring the message.
+	MentionReply func(*discordgo.MessageCreate) string
+
+	// IgnoreSelf, IgnoreBots and IgnoreWebhooks when true ignore messages
+	// sent by the bot itself, by other bots and by webhooks. New sets all of
+	// them to true, which prevents commands like echo from triggering
+	// themselves or other bots in loops.
+	IgnoreSelf     bool
+	IgnoreBots     bool
+	IgnoreWebhooks bool
+
+	// AllowBots contains the user IDs of bots that may use commands even if
+	// IgnoreBots is true.
+	AllowBots []string
+
+	// EditCommands when true runs commands again when their message is edited.
+	// Responses sent with Send or Reply while the command runs again edit the
+	// messages sent the first time, in order, instead of sending new ones.
+	EditCommands bool
+
+	// DeleteResponses when true deletes the messages sent with Send or Reply
+	// in response to a command when the command's message is deleted.
+	DeleteResponses bool
+
+	// TrackedCommands is the number of recent commands whose responses are
+	// remembered for EditCommands and DeleteResponses, and TrackedCommandsAge
+	// is how long they're remembered for, or forever if 0. New sets them to
+	// 1000 and an hour.
+	TrackedCommands    int
+	TrackedCommandsAge time.Duration
+
+	// MatchMode is how typed command names are matched. The default is
+	// MatchExact.
+	MatchMode MatchMode
+
 	// FormatError formats any errors returned by anything, including the method
 	// commands or the reflect functions. This also includes invalid usage
 	// errors or unknown command errors. Returning an empty string means
@@ -37,8 +83,33 @@ type Context struct {
 	// ReplyError when true replies to the user the error.
 	ReplyError bool
 
-	// Subcommands contains all the registered subcommands.
-	Subcommands []*Subcommand
+	// NoRecover when true lets panics while dispatching commands crash the
+	// program instead of turning them into an *ErrPanic. This is mostly useful
+	// for tests.
+	NoRecover bool
+
+	// Cooldowns stores the cooldown buckets of commands. If nil, cooldowns
+	// aren't enforced.
+	Cooldowns CooldownStore
+
+	// ACL contains each guild's rules for who may use commands and where, or
+	// nil to not check any.
+	ACL ACLStore
+
+	// IgnoreRestrictedChannels when true silently ignores commands that the
+	// ACL denies in the channel, instead of replying with an
+	// *ErrChannelRestricted.
+	IgnoreRestrictedChannels bool
+
+	// Owners contains the user IDs of the bot's owners, which are the only
+	// users allowed to use OwnerOnly commands. If empty, StartBot fills it
+	// with the application owner.
+	Owners []string
+
+	// responses tracks the responses of running and recent commands.
+	responses *responseTracker
+	// helpPagers tracks the help messages sent by SendHelp.
+	helpPagers *helpPagers
 }
 
 // StartBot quickly starts a bot with the given command. It will prepend "Bot"
@@ -62,6 +133,13 @@ func StartBot(token string, cmd interface{},
 		}
 	}
 
+	// Owners is read by the handlers, so it's filled in before they start.
+	if len(c.Owners) == 0 {
+		if err := c.FetchOwners(); err != nil {
+			c.ErrorLogger(err)
+		}
+	}
+
 	cancel := c.Start()
 
 	if err := s.Open(); err != nil {
@@ -104,12 +182,26 @@ func New(s *discordgo.Session, cmd interface{}) (*Context, error) {
 		Session:    s,
 		Prefix:     "~",
 		FormatError: func(err error) string {
+			// Don't leak the stack trace to users.
+			if _, ok := err.(*ErrPanic); ok {
+				return "Something went wrong while running this command."
+			}
+
 			return err.Error()
 		},
 		ErrorLogger: func(err error) {
 			log.Println("Bot error:", err)
 		},
-		ReplyError: true,
+		ReplyError:     true,
+		Cooldowns:      NewMemoryCooldownStore(),
+		IgnoreSelf:     true,
+		IgnoreBots:     true,
+		IgnoreWebhooks: true,
+
+		TrackedCommands:    1000,
+		TrackedCommandsAge: time.Hour,
+		responses:          newResponseTracker(),
+		helpPagers:         newHelpPagers(),
 	}
 
 	if err := ctx.InitCommands(ctx); err != nil {
@@ -119,48 +211,48 @@ func New(s *discordgo.Session, cmd interface{}) (*Context, error) {
 	return ctx, nil
 }
 
-func (ctx *Context) RegisterSubcommand(cmd interface{}) (*Subcommand, error) {
-	s, err := NewSubcommand(cmd)
-	if err != nil {
-		return nil, errors.Wrap(err, "Failed to add subcommand")
-	}
-
-	// Register the subcommand's name.
-	s.NeedsName()
-
-	if err := s.InitCommands(ctx); err != nil {
-		return nil, errors.Wrap(err, "Failed to initialize subcommand")
-	}
-
-	// Do a collision check
-	for _, sub := range ctx.Subcommands {
-		if sub.name == s.name {
-			return nil, errors.New(
-				"New subcommand has duplicate name: " + s.name)
-		}
-	}
-
-	ctx.Subcommands = append(ctx.Subcommands, s)
-	return s, nil
-}
-
 // Start adds itself into the discordgo Session handlers. This needs to be run.
 // The returned function is a delete function, which removes itself from the
 // Session handlers.
 func (ctx *Context) Start() func() {
 	return ctx.Session.AddHandler(func(_ *discordgo.Session, v interface{}) {
+		// Remember the responses to commands, so they can be edited later.
+		var inv *invocation
+
+		m, edit := ctx.trackedMessage(v)
+		if m != nil {
+			inv = ctx.responses.begin(m, edit)
+
+			defer func() {
+				// Responses that the command no longer sends are deleted.
+				unused := ctx.responses.end(inv, ctx.TrackedCommands, ctx.TrackedCommandsAge)
+
+				if err := ctx.deleteMessages(m.ChannelID, unused); err != nil {
+					ctx.ErrorLogger(err)
+				}
+			}()
+		}
+
 		if err := ctx.callCmd(v); err != nil {
 			if str := ctx.FormatError(err); str != "" {
 				// Log the main error first
 				ctx.ErrorLogger(errors.Wrap(err, str))
 
-				mc, ok := v.(*discordgo.MessageCreate)
-				if !ok {
+				// Only reply to messages, including edited ones. Errors of
+				// other events, such as panicking handlers, are only logged.
+				switch v.(type) {
+				case *discordgo.MessageCreate, *discordgo.MessageUpdate:
+				default:
+					return
+				}
+
+				channelID := reflectChannelID(v)
+				if channelID == "" {
 					return
 				}
 
 				if ctx.ReplyError {
-					_, Merr := ctx.Session.ChannelMessageSend(mc.ChannelID, str)
+					_, Merr := ctx.send(inv, channelID, str)
 					if Merr != nil {
 						// Then the message error
 						ctx.ErrorLogger(Merr)
@@ -178,115 +270,19 @@ func (ctx *Context) Call(event interface{}) error {
 }
 
 // Send sends a string, an embed pointer or a MessageSend pointer. Any other
-// type given will panic.
-func (ctx *Context) Send(channelID string, content interface{}) (err error) {
-	switch content := content.(type) {
-	case string:
-		_, err = ctx.Session.ChannelMessageSend(channelID, content)
-	case *discordgo.MessageEmbed:
-		_, err = ctx.Session.ChannelMessageSendEmbed(channelID, content)
-	case *discordgo.MessageSend:
-		_, err = ctx.Session.ChannelMessageSendComplex(channelID, content)
-	default:
-		// BUG
-		panic("Send received an unknown content type")
-	}
-
-	return
+// type given will panic. If a command is being run again with EditCommands,
+// its previous response is edited instead.
+func (ctx *Context) Send(channelID string, content interface{}) error {
+	_, err := ctx.send(ctx.responses.current(channelID, ""), channelID, content)
+	return err
 }
 
-// Reply mentions the user when sending the message.
+// Reply mentions the user when sending the message. Like Send, it edits the
+// previous reply to m if m is being run again with EditCommands.
 func (ctx *Context) Reply(m *discordgo.Message, reply string) error {
-	return ctx.Send(m.ChannelID, m.Author.Mention()+", "+reply)
-}
-
-// Help generates one. This function is used more for reference than an actual
-// help message. As such, it only uses exported fields or methods.
-func (ctx *Context) Help() string {
-	var help strings.Builder
-
-	// Generate the headers and descriptions
-	help.WriteString("__Help__")
-
-	if ctx.Name != "" {
-		help.WriteString(": " + ctx.Name)
-	}
-
-	if ctx.Description != "" {
-		help.WriteString("\n      " + ctx.Description)
-	}
-
-	if ctx.Flag.Is(AdminOnly) {
-		// That's it.
-		return help.String()
-	}
-
-	// Separators
-	help.WriteString("\n---\n")
-
-	// Generate all commands
-	help.WriteString("__Commands__\n")
-
-	for _, cmd := range ctx.Commands {
-		if cmd.Flag.Is(AdminOnly) {
-			// Hidden
-			continue
-		}
-
-		help.WriteString("      " + ctx.Prefix + cmd.Name())
-
-		switch {
-		case len(cmd.Usage()) > 0:
-			help.WriteString(" " + strings.Join(cmd.Usage(), " "))
-		case cmd.Description != "":
-			help.WriteString(": " + cmd.Description)
-		}
-
-		help.WriteByte('\n')
-	}
-
-	var subHelp = strings.Builder{}
-
-	for _, sub := range ctx.Subcommands {
-		if sub.Flag.Is(AdminOnly) {
-			// Hidden
-			continue
-		}
-
-		subHelp.WriteString("      " + sub.Name())
-
-		if sub.Description != "" {
-			subHelp.WriteString(": " + sub.Description)
-		}
-
-		subHelp.WriteByte('\n')
-
-		for _, cmd := range sub.Commands {
-			if cmd.Flag.Is(AdminOnly) {
-				continue
-			}
-
-			subHelp.WriteString("            " +
-				ctx.Prefix + sub.Name() + " " + cmd.Name())
-
-			switch {
-			case len(cmd.Usage()) > 0:
-				subHelp.WriteString(" " + strings.Join(cmd.Usage(), " "))
-			case cmd.Description != "":
-				subHelp.WriteString(": " + cmd.Description)
-			}
-
-			subHelp.WriteByte('\n')
-		}
-	}
-
-	if sub := subHelp.String(); sub != "" {
-		help.WriteString("---\n")
-		help.WriteString("__Subcommands__\n")
-		help.WriteString(sub)
-	}
-
-	return help.String()
+	_, err := ctx.send(ctx.responses.current(m.ChannelID, m.ID),
+		m.ChannelID, m.Author.Mention()+", "+reply)
+	return err
 }
 
 // Member returns the member, adding it to the State.
@@ -344,121 +340,161 @@ func (ctx *Context) Channel(channelID string) (*discordgo.Channel, error) {
 	return c, nil
 }
 
-func (ctx *Context) callCmd(ev interface{}) error {
+func (ctx *Context) callCmd(ev interface{}) (err error) {
+	// Panics outside of commands and middlewares, such as in argument parsers
+	// or PrefixFunc, are recovered here.
+	if !ctx.NoRecover {
+		defer recoverPanic(nil, &err)
+	}
+
 	evT := reflect.TypeOf(ev)
 
 	if evT != typeMessageCreate {
-		var callers []reflect.Value
 		var isAdmin *bool // i want to die
 
-		for _, cmd := range ctx.Commands {
-			if cmd.event == evT {
-				if cmd.Flag.Is(AdminOnly) &&
-					!ctx.eventIsAdmin(ev, &isAdmin) {
-
-					continue
-				}
-
-				callers = append(callers, cmd.value)
+		for _, cmd := range ctx.eventCallers(ctx.Subcommand, ev, evT, &isAdmin) {
+			if err := ctx.invoke(cmd, ev, nil); err != nil {
+				ctx.ErrorLogger(err)
 			}
 		}
 
-		for _, sub := range ctx.Subcommands {
-			if sub.Flag.Is(AdminOnly) &&
-				!ctx.eventIsAdmin(ev, &isAdmin) {
-
-				continue
+		switch ev := ev.(type) {
+		case *discordgo.MessageUpdate:
+			// Edited messages are run again as if they were new.
+			if ctx.rerun(ev) {
+				return ctx.callMessage(&discordgo.MessageCreate{Message: ev.Message})
 			}
 
-			for _, cmd := range sub.Commands {
-				if cmd.event == evT {
-					if cmd.Flag.Is(AdminOnly) &&
-						!ctx.eventIsAdmin(ev, &isAdmin) {
-
-						continue
-					}
-
-					callers = append(callers, cmd.value)
+		case *discordgo.MessageDelete:
+			if ctx.DeleteResponses && ev.Message != nil {
+				if err := ctx.deleteResponses(ev.ChannelID, ev.ID); err != nil {
+					ctx.ErrorLogger(err)
 				}
 			}
-		}
 
-		for _, c := range callers {
-			if err := callWith(c, ev); err != nil {
+		case *discordgo.MessageReactionAdd:
+			if err := ctx.turnHelpPage(ev.MessageReaction); err != nil {
 				ctx.ErrorLogger(err)
 			}
+
+		case *discordgo.MessageDeleteBulk:
+			if ctx.DeleteResponses {
+				if err := ctx.deleteResponses(ev.ChannelID, ev.Messages...); err != nil {
+					ctx.ErrorLogger(err)
+				}
+			}
 		}
 
 		return nil
 	}
 
 	// safe assertion always
-	mc := ev.(*discordgo.MessageCreate)
+	return ctx.callMessage(ev.(*discordgo.MessageCreate))
+}
+
+// callMessage runs the command in the message, if there's any.
+func (ctx *Context) callMessage(mc *discordgo.MessageCreate) error {
+	if ctx.ignoreMessage(mc.Message) {
+		return nil
+	}
 
 	// check if prefix
-	if !strings.HasPrefix(mc.Content, ctx.Prefix) {
+	prefix, ok := ctx.matchPrefix(mc)
+	if !ok {
 		// not a command, ignore
 		return nil
 	}
 
+	ctx.responses.markCommand(mc.ChannelID, mc.ID)
+
 	// trim the prefix before splitting, this way multi-words prefices work
-	content := mc.Content[len(ctx.Prefix):]
+	content := mc.Content[len(prefix):]
 
 	// parse arguments
-	args, err := ParseArgs(content)
+	tokens, err := ParseArgs(content)
+
+	// A syntax error could be in the content given to Remaining, which is
+	// kept as it is, so it's only returned if the command doesn't take one.
+	var invalid Token
+	var syntaxErr *ErrSyntax
+
 	if err != nil {
-		return err
+		var ok bool
+		if syntaxErr, ok = err.(*ErrSyntax); !ok {
+			return err
+		}
+
+		syntaxErr.Prefix = prefix
+
+		if tokens, invalid, err = splitInvalid(content, syntaxErr); err != nil {
+			return syntaxErr
+		}
 	}
 
-	if len(args) < 1 {
+	args := tokenValues(tokens)
+
+	if len(args) < 1 && syntaxErr == nil {
+		if _, ok := ctx.mentionPrefix(mc.Content); ok {
+			return ctx.replyMention(mc)
+		}
+
 		return nil // ???
 	}
 
 	var cmd *CommandContext
+	var sub = ctx.Subcommand
 	var start int // arg starts from $start
 
-	// Search for the command
-	for _, c := range ctx.Commands {
-		if c.name == args[0] {
-			cmd = c
-			start = 1
+	// Walk down the subcommands until a command is found
+	for cmd == nil {
+		if start == len(args) {
+			if syntaxErr != nil {
+				return syntaxErr
+			}
+
+			return newErrUnknownCommand(prefix, args[:start], "", sub)
+		}
+
+		var child *Subcommand
+
+		if cmd, child = sub.lookup(ctx.MatchMode, args[start]); cmd != nil {
+			start++
 			break
 		}
-	}
 
-	// Can't find command, look for subcommands of len(args) has a 2nd
-	// entry.
-	if cmd == nil && len(args) > 1 {
-		for _, s := range ctx.Subcommands {
-			if s.name != args[0] {
-				continue
-			}
+		if child == nil {
+			return newErrUnknownCommand(prefix, args[:start], args[start], sub)
+		}
 
-			for _, c := range s.Commands {
-				if c.name == args[1] {
-					cmd = c
-					start = 2
-					break
-				}
-			}
+		sub = child
+		start++
+	}
 
-			if cmd == nil {
-				return &ErrUnknownCommand{
-					Command: args[1],
-					Parent:  args[0],
-					Prefix:  ctx.Prefix,
-					ctx:     s.Commands,
-				}
-			}
+	// Variadic and Remaining arguments are never required, and they take any
+	// amount.
+	var fixed = len(cmd.arguments)
+	if cmd.variadic || cmd.remaining {
+		fixed--
+	}
+
+	if syntaxErr != nil {
+		// The invalid token has to be in the Remaining argument.
+		if !cmd.remaining || cmd.parseType != nil || len(args) < start+fixed {
+			return syntaxErr
 		}
+
+		tokens = append(tokens, invalid)
+		args = append(args, invalid.Value)
 	}
 
-	if cmd == nil || start == 0 {
-		return &ErrUnknownCommand{
-			Command: args[0],
-			Prefix:  ctx.Prefix,
-			ctx:     ctx.Commands,
+	var isAdmin *bool
+
+	if err := ctx.authorize(cmd, mc, &isAdmin); err != nil {
+		if _, ok := err.(*ErrChannelRestricted); ok && ctx.IgnoreRestrictedChannels {
+			return nil
 		}
+
+		return err
 	}
 
 	// Start converting
@@ -492,36 +528,125 @@ func (ctx *Context) callCmd(ev interface{}) error {
 	}
 
 	// Not enough arguments given
-	if len(args[start:]) != len(cmd.arguments) {
+	if len(args[start:]) < fixed-cmd.optional {
+		return &ErrInvalidUsage{
+			Args:    args,
+			Prefix:  prefix,
+			Content: content,
+			Tokens:  tokens,
+			Index:   len(args),
+			Err:     "Not enough arguments given",
+			ctx:     cmd,
+		}
+	}
+
+	if !cmd.variadic && !cmd.remaining && len(args[start:]) > fixed {
 		return &ErrInvalidUsage{
-			Args:   args,
-			Prefix: ctx.Prefix,
-			Index:  len(cmd.arguments) - start,
-			Err:    "Not enough arguments given",
-			ctx:    cmd,
+			Args:    args,
+			Prefix:  prefix,
+			Content: content,
+			Tokens:  tokens,
+			Index:   start + fixed,
+			Err:     "Too many arguments given",
+			ctx:     cmd,
 		}
 	}
 
-	argv = make([]reflect.Value, len(cmd.arguments))
+	argv = make([]reflect.Value, 0, len(args)-start)
 
 	for i := start; i < len(args); i++ {
-		v, err := cmd.arguments[i-start](args[i])
+		// Variadic arguments all use the last argument's parser.
+		var j = i - start
+		if j >= len(cmd.arguments) {
+			j = len(cmd.arguments) - 1
+		}
+
+		var arg = args[i]
+
+		// Remaining takes the raw content from this token onwards.
+		if cmd.remaining && j == fixed {
+			arg = content[tokens[i].Start:]
+			i = len(args)
+		}
+
+		v, err := cmd.arguments[j](arg)
 		if err != nil {
 			return &ErrInvalidUsage{
-				Args:   args,
-				Prefix: ctx.Prefix,
-				Index:  i,
-				Err:    err.Error(),
-				ctx:    cmd,
+				Args:    args,
+				Prefix:  prefix,
+				Content: content,
+				Tokens:  tokens,
+				Index:   i,
+				Err:     err.Error(),
+				ctx:     cmd,
 			}
 		}
 
-		argv[i-start] = v
+		argv = append(argv, v)
+	}
+
+	// Omitted optional arguments are given as their zero values, which is nil
+	// for pointers.
+	for i := len(argv); i < fixed; i++ {
+		argv = append(argv, reflect.Zero(cmd.value.Type().In(i+1)))
+	}
+
+	if cmd.remaining && len(argv) == fixed {
+		argv = append(argv, reflect.Zero(typeRemaining))
 	}
 
 Call:
-	// call the function and parse the error return value
-	return callWith(cmd.value, ev, argv...)
+	if err := ctx.checkCooldown(cmd, mc); err != nil {
+		return err
+	}
+
+	// call the function through the middlewares and parse the error return
+	// value
+	return ctx.invoke(cmd, mc, argv)
+}
+
+// eventCallers returns all commands in the subcommand and its children that
+// handle the event type.
+func (ctx *Context) eventCallers(sub *Subcommand,
+	ev interface{}, evT reflect.Type, isAdmin **bool) []*CommandContext {
+
+	var callers []*CommandContext
+
+	for _, cmd := range sub.Commands {
+		if cmd.event == evT && ctx.authorize(cmd, ev, isAdmin) == nil {
+			callers = append(callers, cmd)
+		}
+	}
+
+	for _, child := range sub.Subcommands {
+		callers = append(callers, ctx.eventCallers(child, ev, evT, isAdmin)...)
+	}
+
+	return callers
+}
+
+// ignoreMessage returns true if the message's author shouldn't be able to use
+// commands. Messages without an author are never ignored.
+func (ctx *Context) ignoreMessage(m *discordgo.Message) bool {
+	if m.Author == nil {
+		return false
+	}
+
+	if ctx.IgnoreWebhooks && m.WebhookID != "" {
+		return true
+	}
+
+	if ctx.IgnoreSelf && ctx.Session != nil && ctx.State != nil &&
+		ctx.State.User != nil && m.Author.ID == ctx.State.User.ID {
+
+		return true
+	}
+
+	if ctx.IgnoreBots && m.Author.Bot && !contains(ctx.AllowBots, m.Author.ID) {
+		return true
+	}
+
+	return false
 }
 
 func (ctx *Context) eventIsAdmin(ev interface{}, is **bool) bool {
@@ -557,16 +682,6 @@ func callWith(caller reflect.Value, ev interface{}, values ...reflect.Value) err
 	)))
 }
 
-var ParseArgs = func(args string) ([]string, error) {
-	// fuck me
-	// TODO: make modular
-	// TODO: actual tokenizer+parser
-	r := csv.NewReader(strings.NewReader(args))
-	r.Comma = ' '
-
-	return r.Read()
-}
-
 func errorReturns(returns []reflect.Value) error {
 	// assume first is always error, since we checked for this in parseCommands
 	v := returns[0].Interface()
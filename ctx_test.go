@@ -24,6 +24,21 @@ func (t *testCommands) Custom(_ *discordgo.MessageCreate, c *CustomParseable) er
 	return nil
 }
 
+func (t *testCommands) Optional(_ *discordgo.MessageCreate, name string, count *int) error {
+	t.Return <- []interface{}{name, count}
+	return nil
+}
+
+func (t *testCommands) Sum(_ *discordgo.MessageCreate, name string, nums ...int) error {
+	t.Return <- []interface{}{name, nums}
+	return nil
+}
+
+func (t *testCommands) Note(_ *discordgo.MessageCreate, id int, text Remaining) error {
+	t.Return <- []interface{}{id, text}
+	return nil
+}
+
 func (t *testCommands) NoArgs(_ *discordgo.MessageCreate) error {
 	return errors.New("passed")
 }
@@ -32,6 +47,28 @@ func (t *testCommands) Noop(_ *discordgo.MessageCreate) error {
 	return nil
 }
 
+func (t *testCommands) Aliases() []string {
+	return []string{"TC"}
+}
+
+func (t *testCommands) CommandAliases() map[string][]string {
+	return map[string][]string{
+		"noop": {"nop", "NOTHING"},
+	}
+}
+
+func (t *testCommands) CommandDescriptions() map[string]string {
+	return map[string]string{
+		"sum": "adds numbers up",
+	}
+}
+
+func (t *testCommands) CommandExamples() map[string][]string {
+	return map[string][]string{
+		"sum": {"total 1 2 3"},
+	}
+}
+
 type CustomParseable struct {
 	args []string
 }
@@ -134,6 +171,65 @@ func TestContext(t *testing.T) {
 		}
 	})
 
+	t.Run("call command optional arguments", func(t *testing.T) {
+		ctx.Prefix = "~"
+		count := 3
+
+		if err := testReturn([]interface{}{"a", (*int)(nil)}, "~optional a"); err != nil {
+			t.Fatal("unexpected call error:", err)
+		}
+
+		if err := testReturn([]interface{}{"a", &count}, "~optional a 3"); err != nil {
+			t.Fatal("unexpected call error:", err)
+		}
+	})
+
+	t.Run("call command variadic arguments", func(t *testing.T) {
+		ctx.Prefix = "~"
+
+		if err := testReturn([]interface{}{"a", []int{}}, "~sum a"); err != nil {
+			t.Fatal("unexpected call error:", err)
+		}
+
+		if err := testReturn([]interface{}{"a", []int{1, 2, 3}}, "~sum a 1 2 3"); err != nil {
+			t.Fatal("unexpected call error:", err)
+		}
+	})
+
+	t.Run("call command remaining argument", func(t *testing.T) {
+		ctx.Prefix = "~"
+
+		expects := []interface{}{1, Remaining(`keep  "this"   as is`)}
+
+		if err := testReturn(expects, `~note 1  keep  "this"   as is`); err != nil {
+			t.Fatal("unexpected call error:", err)
+		}
+
+		if err := testReturn([]interface{}{1, Remaining("")}, "~note 1"); err != nil {
+			t.Fatal("unexpected call error:", err)
+		}
+
+		// Unbalanced quotes and code blocks are fine in the remaining content.
+		for _, text := range []string{`he said "hi`, `'tis`, `a"b c`, "```go"} {
+			expects := []interface{}{1, Remaining(text)}
+
+			if err := testReturn(expects, "~note 1 "+text); err != nil {
+				t.Fatal("unexpected call error:", err)
+			}
+		}
+
+		// They're still invalid before it.
+		for _, content := range []string{`~note "1 hi`, `~"note 1 hi`, `~sum "a`} {
+			err := ctx.callCmd(&discordgo.MessageCreate{
+				Message: &discordgo.Message{Content: content},
+			})
+
+			if _, ok := err.(*ErrSyntax); !ok {
+				t.Fatalf("expected syntax error for %q, got %v", content, err)
+			}
+		}
+	})
+
 	testMessage := func(content string) error {
 		// Mock a messageCreate event
 		m := &discordgo.MessageCreate{
@@ -165,6 +261,55 @@ func TestContext(t *testing.T) {
 		}
 	})
 
+	t.Run("call unknown command with suggestions", func(t *testing.T) {
+		ctx.Prefix = "~"
+
+		err := testMessage("~nopo")
+
+		unknown, ok := err.(*ErrUnknownCommand)
+		if !ok {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if !reflect.DeepEqual(unknown.Suggestions, []string{"noop"}) {
+			t.Fatal("unexpected suggestions:", unknown.Suggestions)
+		}
+
+		if s := err.Error(); s != "Unknown command: ~nopo, did you mean ~noop?" {
+			t.Fatal("unexpected error:", s)
+		}
+	})
+
+	t.Run("call command with wrong arity", func(t *testing.T) {
+		ctx.Prefix = "~"
+
+		var entries = map[string]string{
+			"~optional":       "Usage: ~optional __string__ [int]",
+			"~optional a 3 4": "Usage: ~optional string [int]",
+			"~optional a b":   "Usage: ~optional string __[int]__",
+			"~sum":            "Usage: ~sum __string__ int...",
+			"~sum a 1 b":      "Usage: ~sum string __int...__",
+			"~note x":         "Usage: ~note __int__ text...",
+		}
+
+		for content, usage := range entries {
+			err, ok := testMessage(content).(*ErrInvalidUsage)
+			if !ok {
+				t.Fatal("expected invalid usage for", content)
+			}
+
+			if !strings.HasSuffix(err.Error(), "\n"+usage) {
+				t.Fatalf("unexpected error for %q: %s", content, err)
+			}
+
+			// Any index must be safe to format.
+			for i := -1; i <= len(err.Args)+1; i++ {
+				err.Index = i
+				_ = err.Error()
+			}
+		}
+	})
+
 	// Test subcommands
 
 	t.Run("register subcommand", func(t *testing.T) {
@@ -179,6 +324,475 @@ func TestContext(t *testing.T) {
 			t.Fatal("unexpected error:", err)
 		}
 	})
+
+	t.Run("call aliases", func(t *testing.T) {
+		ctx.Prefix = "run "
+
+		for _, content := range []string{
+			"run nop", "run nothing", "run tc noop", "run tc nop",
+		} {
+			if err := testMessage(content); err != nil {
+				t.Fatal("unexpected error for", content, err)
+			}
+		}
+
+		if help := ctx.Help(); !strings.Contains(help, "testcommands (aliases: tc)") {
+			t.Fatal("subcommand aliases missing from help:\n" + help)
+		}
+
+		// Descriptions are shown along with the usage.
+		if help := ctx.Help(); !strings.Contains(help, "run sum string int...: adds numbers up\n") {
+			t.Fatal("description missing from help:\n" + help)
+		}
+
+		embed, err := ctx.CommandHelp(nil, "sum")
+		if err != nil {
+			t.Fatal("Failed to get command help:", err)
+		}
+
+		if embed.Description != "adds numbers up" || len(embed.Fields) < 2 ||
+			embed.Fields[1].Value != "`run sum total 1 2 3`" {
+
+			t.Fatal("unexpected command help:", embed.Description, embed.Fields)
+		}
+	})
+
+	t.Run("register nested subcommand", func(t *testing.T) {
+		ctx.Prefix = "run "
+
+		sub := ctx.Subcommands[0]
+
+		nested, err := sub.RegisterSubcommand(&testCommands{})
+		if err != nil {
+			t.Fatal("Failed to register nested subcommand:", err)
+		}
+
+		if path := strings.Join(nested.Path(), " "); path != "testcommands testcommands" {
+			t.Fatal("unexpected path:", path)
+		}
+
+		if _, err := sub.RegisterSubcommand(&testCommands{}); err == nil {
+			t.Fatal("expected duplicate subcommand error")
+		}
+
+		if err := testMessage("run testcommands testcommands noop"); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		err = testMessage("run testcommands testcommands nope")
+
+		unknown, ok := err.(*ErrUnknownCommand)
+		if !ok {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if unknown.Parent != "testcommands testcommands" || unknown.Command != "nope" {
+			t.Fatal("unexpected unknown command:", unknown.Parent, unknown.Command)
+		}
+
+		help := ctx.Help()
+		if !strings.Contains(help, "\n                  run testcommands testcommands noop (aliases: nop, nothing)\n") {
+			t.Fatal("nested command missing from help:\n" + help)
+		}
+	})
+}
+
+type panicCommands struct {
+	Ctx *Context
+}
+
+func (p *panicCommands) Die(_ *discordgo.MessageCreate) error {
+	panic("oh no")
+}
+
+func (p *panicCommands) OnTyping(_ *discordgo.TypingStart) error {
+	panic("oh no")
+}
+
+// panicArgument panics while being parsed.
+type panicArgument struct{}
+
+func (a *panicArgument) Parse(string) error {
+	panic("oh no")
+}
+
+func (p *panicCommands) Parse(_ *discordgo.MessageCreate, _ *panicArgument) error {
+	return nil
+}
+
+func TestPanicRecovery(t *testing.T) {
+	ctx, err := New(&discordgo.Session{}, &panicCommands{})
+	if err != nil {
+		t.Fatal("Failed to create new context:", err)
+	}
+
+	var logged []error
+	ctx.ErrorLogger = func(err error) {
+		logged = append(logged, err)
+	}
+
+	err = ctx.Call(&discordgo.MessageCreate{
+		Message: &discordgo.Message{Content: "~die"},
+	})
+
+	p, ok := err.(*ErrPanic)
+	if !ok {
+		t.Fatal("expected panic error, got", err)
+	}
+
+	if p.Value != "oh no" || len(p.Stack) == 0 {
+		t.Fatal("unexpected panic error:", p.Value, string(p.Stack))
+	}
+
+	if str := ctx.FormatError(err); strings.Contains(str, "goroutine") {
+		t.Fatal("stack trace leaked into the formatted error:", str)
+	}
+
+	if err := ctx.Call(&discordgo.TypingStart{}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(logged) != 1 {
+		t.Fatal("expected the event handler panic to be logged, got", logged)
+	}
+
+	// Panics outside of commands are recovered as well.
+	if _, ok := ctx.Call(&discordgo.MessageCreate{
+		Message: &discordgo.Message{Content: "~parse x"},
+	}).(*ErrPanic); !ok {
+		t.Fatal("expected panic error from the argument parser")
+	}
+
+	ctx.PrefixFunc = func(*discordgo.MessageCreate) []string {
+		panic("oh no")
+	}
+
+	p, ok = ctx.Call(&discordgo.MessageCreate{
+		Message: &discordgo.Message{Content: "~die"},
+	}).(*ErrPanic)
+
+	if !ok || p.Command != nil || !strings.Contains(p.Error(), "dispatch panicked") {
+		t.Fatal("expected panic error from PrefixFunc, got", p)
+	}
+
+	ctx.PrefixFunc = nil
+
+	t.Run("no recover", func(t *testing.T) {
+		ctx.NoRecover = true
+
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected a raw panic")
+			}
+		}()
+
+		ctx.Call(&discordgo.MessageCreate{
+			Message: &discordgo.Message{Content: "~die"},
+		})
+	})
+}
+
+func TestIgnoreMessages(t *testing.T) {
+	var state = discordgo.NewState()
+	state.User = &discordgo.User{ID: "self", Bot: true}
+
+	ctx, err := New(&discordgo.Session{State: state}, &prefixCommands{})
+	if err != nil {
+		t.Fatal("Failed to create new context:", err)
+	}
+
+	ctx.AllowBots = []string{"friend", "self"}
+
+	var tests = []struct {
+		author  *discordgo.User
+		webhook string
+		ignored bool
+	}{
+		{nil, "", false},
+		{&discordgo.User{ID: "user"}, "", false},
+		{&discordgo.User{ID: "self", Bot: true}, "", true},
+		{&discordgo.User{ID: "bot", Bot: true}, "", true},
+		{&discordgo.User{ID: "friend", Bot: true}, "", false},
+		{&discordgo.User{ID: "hook", Bot: true}, "hook", true},
+	}
+
+	for _, test := range tests {
+		// Unknown commands error out unless the message is ignored.
+		err := ctx.Call(&discordgo.MessageCreate{
+			Message: &discordgo.Message{
+				Content:   "~pong",
+				Author:    test.author,
+				WebhookID: test.webhook,
+			},
+		})
+
+		if ignored := err == nil; ignored != test.ignored {
+			t.Fatal("unexpected result for", test.author, test.webhook, err)
+		}
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		ctx.IgnoreSelf = false
+		ctx.IgnoreBots = false
+		ctx.IgnoreWebhooks = false
+
+		for _, test := range tests {
+			err := ctx.Call(&discordgo.MessageCreate{
+				Message: &discordgo.Message{
+					Content:   "~pong",
+					Author:    test.author,
+					WebhookID: test.webhook,
+				},
+			})
+
+			if _, ok := err.(*ErrUnknownCommand); !ok {
+				t.Fatal("expected unknown command for", test.author, "got", err)
+			}
+		}
+	})
+}
+
+type authCommands struct {
+	Ctx *Context
+}
+
+func (a *authCommands) AーEcho(_ *discordgo.MessageCreate) error {
+	return nil
+}
+
+func (a *authCommands) Play(_ *discordgo.MessageCreate) error {
+	return nil
+}
+
+func (a *authCommands) AーOnTyping(_ *discordgo.TypingStart) error {
+	panic("typing")
+}
+
+func (a *authCommands) CommandRoles() map[string][]string {
+	return map[string][]string{
+		"play": {"dj", "mod"},
+	}
+}
+
+type AーAuthSubcommand struct {
+	Ctx *Context
+}
+
+func (a *AーAuthSubcommand) Name() string {
+	return "d"
+}
+
+func (a *AーAuthSubcommand) Die(_ *discordgo.MessageCreate) error {
+	return nil
+}
+
+type roleSubcommand struct {
+	Ctx *Context
+}
+
+func (r *roleSubcommand) Name() string {
+	return "music"
+}
+
+func (r *roleSubcommand) RequiredRoles() []string {
+	return []string{"dj"}
+}
+
+func (r *roleSubcommand) Skip(_ *discordgo.MessageCreate) error {
+	return nil
+}
+
+func TestAuthorize(t *testing.T) {
+	ctx, err := New(newTestSession(t), &authCommands{})
+	if err != nil {
+		t.Fatal("Failed to create new context:", err)
+	}
+
+	if _, err := ctx.RegisterSubcommand(&AーAuthSubcommand{}); err != nil {
+		t.Fatal("Failed to register subcommand:", err)
+	}
+
+	if _, err := ctx.RegisterSubcommand(&roleSubcommand{}); err != nil {
+		t.Fatal("Failed to register subcommand:", err)
+	}
+
+	var call = func(content, userID string) error {
+		return ctx.Call(&discordgo.MessageCreate{
+			Message: &discordgo.Message{
+				Content:   content,
+				GuildID:   "guild",
+				ChannelID: "sfw",
+				Author:    &discordgo.User{ID: userID},
+			},
+		})
+	}
+
+	var tests = []struct {
+		content string
+		userID  string
+		allowed bool
+	}{
+		{"~echo", "admin", true},
+		{"~echo", "user", false},
+		{"~echo", "mod", false},
+		{"~d die", "admin", true},
+		{"~d die", "user", false},
+		{"~play", "mod", true},
+		{"~play", "admin", true},
+		{"~play", "user", false},
+		{"~music skip", "mod", false},
+		{"~music skip", "admin", true},
+	}
+
+	for _, test := range tests {
+		err := call(test.content, test.userID)
+
+		if test.allowed {
+			if err != nil {
+				t.Fatal("unexpected error for", test, err)
+			}
+			continue
+		}
+
+		if _, ok := err.(*ErrForbidden); !ok {
+			t.Fatal("expected forbidden error for", test, "got", err)
+		}
+	}
+
+	t.Run("forbidden details", func(t *testing.T) {
+		forbidden := call("~d die", "user").(*ErrForbidden)
+		if forbidden.Flag != AdminOnly || forbidden.Command.Name() != "die" {
+			t.Fatal("unexpected forbidden error:", forbidden)
+		}
+
+		forbidden = call("~music skip", "user").(*ErrForbidden)
+		if forbidden.Flag != None || !reflect.DeepEqual(forbidden.Roles, []string{"dj"}) {
+			t.Fatal("unexpected forbidden error:", forbidden.Flag, forbidden.Roles)
+		}
+	})
+
+	t.Run("DM", func(t *testing.T) {
+		err := ctx.Call(&discordgo.MessageCreate{
+			Message: &discordgo.Message{
+				Content:   "~echo",
+				ChannelID: "dm",
+				Author:    &discordgo.User{ID: "admin"},
+			},
+		})
+
+		if _, ok := err.(*ErrForbidden); !ok {
+			t.Fatal("expected forbidden error in DMs, got", err)
+		}
+	})
+
+	t.Run("events", func(t *testing.T) {
+		var logged []error
+		ctx.ErrorLogger = func(err error) {
+			logged = append(logged, err)
+		}
+
+		var typing = func(userID string) {
+			ctx.Call(&discordgo.TypingStart{
+				UserID:    userID,
+				ChannelID: "sfw",
+				GuildID:   "guild",
+			})
+		}
+
+		typing("user")
+
+		if len(logged) != 0 {
+			t.Fatal("admin-only event handler called for a user:", logged)
+		}
+
+		typing("admin")
+
+		if len(logged) != 1 {
+			t.Fatal("admin-only event handler not called for an admin:", logged)
+		}
+	})
+}
+
+func TestParseArgs(t *testing.T) {
+	type entry struct {
+		Input  string
+		Expect []string
+	}
+
+	var entries = []entry{{
+		Input:  "",
+		Expect: []string{},
+	}, {
+		Input:  "  spaced   out\t args  ",
+		Expect: []string{"spaced", "out", "args"},
+	}, {
+		Input:  "multi\nline\n\ninput",
+		Expect: []string{"multi", "line", "input"},
+	}, {
+		Input:  `say "hello world" 'and you'`,
+		Expect: []string{"say", "hello world", "and you"},
+	}, {
+		Input:  `foo"bar baz"qux`,
+		Expect: []string{"foobar bazqux"},
+	}, {
+		Input:  `it's" fine" 'so it is'`,
+		Expect: []string{`it's fine`, "so it is"},
+	}, {
+		Input:  `"" ''`,
+		Expect: []string{"", ""},
+	}, {
+		Input:  `escaped\ space \"quote\" \\`,
+		Expect: []string{"escaped space", `"quote"`, `\`},
+	}, {
+		Input:  `"say \"hi\"" "C:\Users" 'no \escapes'`,
+		Expect: []string{`say "hi"`, `C:\Users`, `no \escapes`},
+	}, {
+		Input:  "trailing\\",
+		Expect: []string{`trailing\`},
+	}, {
+		Input:  "eval ```go\nfmt.Println(\"a  b\")\n``` after",
+		Expect: []string{"eval", "```go\nfmt.Println(\"a  b\")\n```", "after"},
+	}, {
+		Input:  "<@123> 日本語 ✨",
+		Expect: []string{"<@123>", "日本語", "✨"},
+	}}
+
+	for _, entry := range entries {
+		tokens, err := ParseArgs(entry.Input)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %v", entry.Input, err)
+		}
+
+		if args := tokenValues(tokens); !reflect.DeepEqual(args, entry.Expect) {
+			t.Fatalf("unexpected arguments for %q: %q", entry.Input, args)
+		}
+	}
+
+	t.Run("offsets", func(t *testing.T) {
+		var input = `a  "b c"  d\ e`
+
+		tokens, err := ParseArgs(input)
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		var spans = []string{"a", `"b c"`, `d\ e`}
+
+		for i, token := range tokens {
+			if span := input[token.Start:token.End]; span != spans[i] {
+				t.Fatalf("unexpected span %d: %q", i, span)
+			}
+		}
+	})
+
+	t.Run("unterminated", func(t *testing.T) {
+		for _, input := range []string{`"abc`, `'abc`, "```abc", `a "b\"`} {
+			_, err := ParseArgs(input)
+			if _, ok := err.(*ErrSyntax); !ok {
+				t.Fatalf("expected syntax error for %q, got %v", input, err)
+			}
+		}
+	})
 }
 
 func BenchmarkConstructor(b *testing.B) {
@@ -1,66 +1,195 @@
 package rfrouter
 
 import (
+	"fmt"
 	"strings"
 )
 
 type ErrUnknownCommand struct {
 	Command string
-	Parent  string
+	// Parent is the space-separated path of subcommands that Command was
+	// looked up in, or empty for top-level commands.
+	Parent string
 
 	Prefix string
 
-	// TODO: list available commands?
-	// Here, as a reminder
-	ctx []*CommandContext
+	// Suggestions contains the commands and subcommands with names close to
+	// Command, closest first. Each suggestion includes its parents, but not
+	// the prefix, e.g. "debug goroutines".
+	Suggestions []string
+
+	ctx *Subcommand
+}
+
+func newErrUnknownCommand(
+	prefix string, parent []string, command string,
+	sub *Subcommand) *ErrUnknownCommand {
+
+	return &ErrUnknownCommand{
+		Command:     command,
+		Parent:      strings.Join(parent, " "),
+		Prefix:      prefix,
+		Suggestions: suggest(sub, command),
+		ctx:         sub,
+	}
 }
 
 func (err *ErrUnknownCommand) Error() string {
 	var header = "Unknown command: " + err.Prefix
-	if err.Parent != "" {
-		header += err.Parent + " " + err.Command
-	} else {
+	switch {
+	case err.Parent == "":
 		header += err.Command
+	case err.Command == "":
+		header += err.Parent
+	default:
+		header += err.Parent + " " + err.Command
+	}
+
+	if len(err.Suggestions) == 0 {
+		return header
+	}
+
+	var suggestions = make([]string, len(err.Suggestions))
+	for i, s := range err.Suggestions {
+		suggestions[i] = err.Prefix + s
+	}
+
+	header += ", did you mean "
+
+	if last := len(suggestions) - 1; last > 0 {
+		header += strings.Join(suggestions[:last], ", ") + " or "
+		suggestions = suggestions[last:]
 	}
 
-	return header
+	return header + suggestions[0] + "?"
 }
 
 type ErrInvalidUsage struct {
 	Args   []string
 	Prefix string
 
+	// Content is the message content with its prefix trimmed, and Tokens are
+	// the tokens parsed from it. Each token is an element in Args.
+	Content string
+	Tokens  []Token
+
+	// Index is the index of the invalid argument in Args. An index equal to
+	// len(Args) means that arguments are missing.
 	Index int
 	Err   string
 
-	// TODO: usage generator?
-	// Here, as a reminder
 	ctx *CommandContext
 }
 
 func (err *ErrInvalidUsage) Error() string {
-	if err.Index == 0 {
-		return "Invalid usage"
-	}
+	body := "Invalid usage at " + err.Prefix
 
-	if len(err.Args) == 0 {
-		return "Missing arguments. Refer to help."
-	}
+	switch {
+	case err.Index < 0 || len(err.Args) == 0:
+		body = "Invalid usage"
 
-	body := "Invalid usage at " + err.Prefix
+	case err.Index < len(err.Tokens) && err.Tokens[err.Index].End <= len(err.Content):
+		// Underline the exact span of the wrong token
+		body += underline(err.Content,
+			err.Tokens[err.Index].Start, err.Tokens[err.Index].End)
+
+	case err.Index < len(err.Args):
+		// Write the first part
+		body += strings.Join(err.Args[:err.Index], " ")
 
-	// Write the first part
-	body += strings.Join(err.Args[:err.Index], " ")
+		// Write the wrong part
+		body += " __" + err.Args[err.Index] + "__ "
 
-	// Write the wrong part
-	body += " __" + err.Args[err.Index] + "__ "
+		// Write the last part
+		body += strings.Join(err.Args[err.Index+1:], " ")
 
-	// Write the last part
-	body += strings.Join(err.Args[err.Index+1:], " ")
+	default:
+		// Arguments are missing, which the usage will point at.
+		body += strings.Join(err.Args, " ")
+	}
 
 	if err.Err != "" {
 		body += "\nError: " + err.Err
 	}
 
+	if usage := err.usage(true); usage != "" {
+		body += "\nUsage: " + usage
+	}
+
 	return body
 }
+
+// Usage returns the full usage of the command, including the prefix and its
+// subcommands, e.g. "~debug say string". It returns an empty string if the
+// command is unknown.
+func (err *ErrInvalidUsage) Usage() string {
+	return err.usage(false)
+}
+
+func (err *ErrInvalidUsage) usage(highlight bool) string {
+	if err.ctx == nil {
+		return ""
+	}
+
+	var path = append(err.ctx.parent.Path(), err.ctx.name)
+	var usage = append([]string(nil), err.ctx.Usage()...)
+
+	// Underline the argument that Index points to, or the first missing one.
+	if slot := err.Index - len(path); highlight && slot >= 0 && len(usage) > 0 {
+		last := len(usage) - 1
+		if slot > last && (err.ctx.variadic || err.ctx.remaining) {
+			slot = last
+		}
+
+		if slot <= last {
+			usage[slot] = "__" + usage[slot] + "__"
+		}
+	}
+
+	return err.Prefix + strings.Join(append(path, usage...), " ")
+}
+
+// ErrSyntax is returned by ParseArgs when the content can't be split into
+// arguments, such as when a quote is never closed.
+type ErrSyntax struct {
+	Content string
+	Prefix  string
+
+	// Start and End are the byte offsets of the invalid span in Content.
+	Start int
+	End   int
+	Err   string
+}
+
+func (err *ErrSyntax) Error() string {
+	return "Invalid syntax at " + err.Prefix +
+		underline(err.Content, err.Start, err.End) + "\nError: " + err.Err
+}
+
+// underline marks content[start:end] with Discord's underline markup.
+func underline(content string, start, end int) string {
+	return content[:start] + "__" + content[start:end] + "__" + content[end:]
+}
+
+// ErrPanic is returned when a command or a middleware panics, or anything else
+// while dispatching a command, such as an argument parser. As its error message
+// contains the stack trace, FormatError should not reply with it.
+type ErrPanic struct {
+	// Command is the panicking command, or nil if the panic happened outside of
+	// commands and middlewares.
+	Command *CommandContext
+
+	// Value is the value given to panic.
+	Value interface{}
+	// Stack is the stack trace of the panicking goroutine.
+	Stack []byte
+}
+
+func (err *ErrPanic) Error() string {
+	if err.Command == nil {
+		return fmt.Sprintf("Command dispatch panicked: %v\n%s", err.Value, err.Stack)
+	}
+
+	return fmt.Sprintf("Command %s panicked: %v\n%s",
+		err.Command.Name(), err.Value, err.Stack)
+}
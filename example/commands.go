@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"time"
 
 	"git.sr.ht/~diamondburned/rfrouter"
 	"git.sr.ht/~diamondburned/rfrouter/extras/arguments"
@@ -14,6 +15,39 @@ type Commands struct {
 	HelloCalled int
 }
 
+// CommandAliases maps ~h to ~help.
+func (c *Commands) CommandAliases() map[string][]string {
+	return map[string][]string{
+		"help": {"h"},
+	}
+}
+
+// CommandDescriptions describes the commands in ~help.
+func (c *Commands) CommandDescriptions() map[string]string {
+	return map[string]string{
+		"hello":    "says hello",
+		"flagdemo": "parses flags",
+		"channel":  "shows information about a channel",
+		"help":     "shows this help, or the help of a command",
+	}
+}
+
+// CommandExamples shows examples in ~help <command>.
+func (c *Commands) CommandExamples() map[string][]string {
+	return map[string][]string{
+		"flagdemo": {`-opt -str "test string" ayy lmao`},
+		"channel":  {"#general"},
+		"help":     {"flagdemo"},
+	}
+}
+
+// CommandCooldowns limits ~hello to once every 5 seconds per user.
+func (c *Commands) CommandCooldowns() map[string]rfrouter.Cooldown {
+	return map[string]rfrouter.Cooldown{
+		"hello": {Bucket: rfrouter.BucketUser, Uses: 1, Per: 5 * time.Second},
+	}
+}
+
 // ~hello
 func (c *Commands) Hello(m *discordgo.MessageCreate) error {
 	c.HelloCalled++
@@ -68,7 +102,7 @@ func (c *Commands) AーEditMessage(m *discordgo.MessageUpdate) error {
 	return nil
 }
 
-// ~help
-func (c *Commands) Help(m *discordgo.MessageCreate) error {
-	return c.Context.Send(m.ChannelID, c.Context.Help())
+// ~help [command...]
+func (c *Commands) Help(m *discordgo.MessageCreate, command ...string) error {
+	return c.Context.SendHelp(m, command...)
 }
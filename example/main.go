@@ -29,7 +29,7 @@ func main() {
 			ctx.Description = "https://git.sr.ht/~diamondburned/rfrouter"
 
 			// Add the subcommand
-			_, err := ctx.RegisterSubcommand(&debug.AーDebug{})
+			_, err := ctx.RegisterSubcommand(&debug.OーDebug{})
 			return err
 		},
 	)
@@ -43,7 +43,7 @@ func main() {
 
 	log.Println("Started bot...")
 
-	sig := make(chan os.Signal)
+	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, os.Interrupt)
 	<-sig
 }
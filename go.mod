@@ -7,4 +7,5 @@ require (
 	github.com/k0kubun/pp v3.0.1+incompatible
 	github.com/mattn/go-colorable v0.1.4 // indirect
 	github.com/pkg/errors v0.8.1
+	golang.org/x/text v0.3.2
 )
@@ -0,0 +1,186 @@
+package rfrouter
+
+import (
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Help generates one. This function is used more for reference than an actual
+// help message. As such, it only uses exported fields or methods.
+func (ctx *Context) Help() string {
+	return ctx.help(nil)
+}
+
+// HelpFor generates a help message for where m was sent. Unlike Help, commands
+// that can't be used there, such as guild-only commands in direct messages,
+// are hidden.
+func (ctx *Context) HelpFor(m *discordgo.MessageCreate) string {
+	return ctx.help(m)
+}
+
+// help generates a help message for where m was sent. If m is nil, commands
+// are shown regardless of where they can be used.
+func (ctx *Context) help(m *discordgo.MessageCreate) string {
+	var help strings.Builder
+	var prefix = ctx.helpPrefix(m)
+
+	// Generate the headers and descriptions
+	help.WriteString("__Help__")
+
+	if ctx.Name != "" {
+		help.WriteString(": " + ctx.Name)
+	}
+
+	if ctx.Description != "" {
+		help.WriteString("\n      " + ctx.Description)
+	}
+
+	if ctx.Flag.Is(AdminOnly) {
+		// That's it.
+		return help.String()
+	}
+
+	// Separators
+	help.WriteString("\n---\n")
+
+	// Generate all commands
+	help.WriteString("__Commands__\n")
+	ctx.writeCommandsHelp(&help, m, prefix, ctx.Subcommand, "      ")
+
+	var subHelp = strings.Builder{}
+
+	for _, sub := range ctx.Subcommands {
+		ctx.writeSubcommandHelp(&subHelp, m, prefix, sub, "      ")
+	}
+
+	if sub := subHelp.String(); sub != "" {
+		help.WriteString("---\n")
+		help.WriteString("__Subcommands__\n")
+		help.WriteString(sub)
+	}
+
+	return help.String()
+}
+
+// helpPrefix returns the prefix that commands are shown with in the help
+// message for m, which is the prefix that m used if it has one.
+func (ctx *Context) helpPrefix(m *discordgo.MessageCreate) string {
+	if m == nil {
+		return ctx.Prefix
+	}
+
+	if prefix, ok := ctx.matchPrefix(m); ok {
+		return prefix
+	}
+
+	if prefixes := ctx.prefixes(m); len(prefixes) > 0 {
+		return prefixes[0]
+	}
+
+	return ctx.Prefix
+}
+
+// writeSubcommandHelp writes the subcommand, its commands and its children
+// into help, indenting each level further.
+func (ctx *Context) writeSubcommandHelp(help *strings.Builder,
+	m *discordgo.MessageCreate, prefix string, sub *Subcommand, indent string) {
+
+	if ctx.helpHidden(sub.Flag, m) {
+		return
+	}
+
+	help.WriteString(indent + sub.Name())
+
+	if sub.Description != "" {
+		help.WriteString(": " + sub.Description)
+	}
+
+	writeFlagsHelp(help, sub.Flag, m)
+	writeAliasesHelp(help, sub.Aliases)
+	help.WriteByte('\n')
+
+	ctx.writeCommandsHelp(help, m, prefix, sub, indent+"      ")
+
+	for _, child := range sub.Subcommands {
+		ctx.writeSubcommandHelp(help, m, prefix, child, indent+"      ")
+	}
+}
+
+// writeCommandsHelp writes the commands of the subcommand into help.
+func (ctx *Context) writeCommandsHelp(help *strings.Builder,
+	m *discordgo.MessageCreate, prefix string, sub *Subcommand, indent string) {
+
+	if path := sub.Path(); len(path) > 0 {
+		prefix += strings.Join(path, " ") + " "
+	}
+
+	for _, cmd := range sub.Commands {
+		if cmd.event != typeMessageCreate || ctx.helpHidden(cmd.Flag, m) {
+			continue
+		}
+
+		help.WriteString(indent + prefix + cmd.Name())
+
+		if usage := cmd.Usage(); len(usage) > 0 {
+			help.WriteString(" " + strings.Join(usage, " "))
+		}
+
+		if cmd.Description != "" {
+			help.WriteString(": " + cmd.Description)
+		}
+
+		writeFlagsHelp(help, cmd.Flag, m)
+		writeAliasesHelp(help, cmd.Aliases)
+		help.WriteByte('\n')
+	}
+}
+
+// helpHidden returns true if a command or subcommand with the flag should not
+// be shown in the help message for m. Owner-only commands are only shown to
+// owners.
+func (ctx *Context) helpHidden(flag NameFlag, m *discordgo.MessageCreate) bool {
+	if flag.Is(AdminOnly) {
+		return true
+	}
+
+	if flag.Is(OwnerOnly) && (m == nil || m.Author == nil || !ctx.IsOwner(m.Author.ID)) {
+		return true
+	}
+
+	if m == nil {
+		return false
+	}
+
+	if flag.Is(GuildOnly) && m.GuildID == "" {
+		return true
+	}
+
+	if flag.Is(DMOnly) && m.GuildID != "" {
+		return true
+	}
+
+	return false
+}
+
+// writeFlagsHelp annotates where a command or subcommand can be used. Commands
+// hidden for m aren't annotated, as they're not shown at all.
+func writeFlagsHelp(help *strings.Builder, flag NameFlag, m *discordgo.MessageCreate) {
+	if m == nil && flag.Is(GuildOnly) {
+		help.WriteString(" (server only)")
+	}
+
+	if m == nil && flag.Is(DMOnly) {
+		help.WriteString(" (DMs only)")
+	}
+
+	if flag.Is(NSFWOnly) {
+		help.WriteString(" (NSFW)")
+	}
+}
+
+func writeAliasesHelp(help *strings.Builder, aliases []string) {
+	if len(aliases) > 0 {
+		help.WriteString(" (aliases: " + strings.Join(aliases, ", ") + ")")
+	}
+}
@@ -0,0 +1,412 @@
+package rfrouter
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/pkg/errors"
+)
+
+const (
+	// helpPageFields is the maximum number of commands on each help page. The
+	// limit of an embed is 25.
+	helpPageFields = 15
+	// helpPageLength is the maximum length of each help page. The limit of an
+	// embed is 6000, which includes the title and the footer.
+	helpPageLength = 5000
+
+	// maxHelpPagers is the number of recent help messages that can be turned.
+	maxHelpPagers = 100
+)
+
+// The reactions that turn help pages.
+const (
+	helpPrevious = "\u2b05\ufe0f"
+	helpNext     = "\u27a1\ufe0f"
+)
+
+// HelpEmbed generates the help message as embed pages, one or more for each
+// subcommand. Like Help, it only uses exported fields or methods.
+func (ctx *Context) HelpEmbed() []*discordgo.MessageEmbed {
+	return ctx.helpEmbed(nil)
+}
+
+// HelpEmbedFor generates the help embed pages for where m was sent. Like
+// HelpFor, commands that can't be used there are hidden.
+func (ctx *Context) HelpEmbedFor(m *discordgo.MessageCreate) []*discordgo.MessageEmbed {
+	return ctx.helpEmbed(m)
+}
+
+func (ctx *Context) helpEmbed(m *discordgo.MessageCreate) []*discordgo.MessageEmbed {
+	var title = "Help"
+	if ctx.Name != "" {
+		title += ": " + ctx.Name
+	}
+
+	var pages = newHelpPages(title, ctx.Description,
+		ctx.commandFields(m, ctx.helpPrefix(m), ctx.Subcommand))
+
+	if !ctx.Flag.Is(AdminOnly) {
+		for _, sub := range ctx.Subcommands {
+			pages = append(pages, ctx.subcommandPages(m, ctx.helpPrefix(m), sub)...)
+		}
+	}
+
+	for i, page := range pages {
+		page.Footer = &discordgo.MessageEmbedFooter{
+			Text: "Page " + strconv.Itoa(i+1) + "/" + strconv.Itoa(len(pages)),
+		}
+	}
+
+	return pages
+}
+
+// subcommandPages returns the pages of the subcommand and of its children. The
+// subcommand always has at least one page, unless it's hidden.
+func (ctx *Context) subcommandPages(m *discordgo.MessageCreate,
+	prefix string, sub *Subcommand) []*discordgo.MessageEmbed {
+
+	if ctx.helpHidden(sub.Flag, m) {
+		return nil
+	}
+
+	var title strings.Builder
+	title.WriteString(prefix + strings.Join(sub.Path(), " "))
+	writeFlagsHelp(&title, sub.Flag, m)
+	writeAliasesHelp(&title, sub.Aliases)
+
+	var pages = newHelpPages(
+		title.String(), sub.Description, ctx.commandFields(m, prefix, sub))
+
+	for _, child := range sub.Subcommands {
+		pages = append(pages, ctx.subcommandPages(m, prefix, child)...)
+	}
+
+	return pages
+}
+
+// commandFields returns a field for each visible command of the subcommand.
+func (ctx *Context) commandFields(m *discordgo.MessageCreate,
+	prefix string, sub *Subcommand) []*discordgo.MessageEmbedField {
+
+	if path := sub.Path(); len(path) > 0 {
+		prefix += strings.Join(path, " ") + " "
+	}
+
+	var fields []*discordgo.MessageEmbedField
+
+	for _, cmd := range sub.Commands {
+		if cmd.event != typeMessageCreate || ctx.helpHidden(cmd.Flag, m) {
+			continue
+		}
+
+		var value strings.Builder
+		value.WriteString(cmd.Description)
+		writeFlagsHelp(&value, cmd.Flag, m)
+		writeAliasesHelp(&value, cmd.Aliases)
+
+		var field = &discordgo.MessageEmbedField{
+			Name:  strings.Join(append([]string{prefix + cmd.Name()}, cmd.Usage()...), " "),
+			Value: strings.TrimSpace(value.String()),
+		}
+
+		// Embed fields can't be empty.
+		if field.Value == "" {
+			field.Value = "\u200b"
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields
+}
+
+// newHelpPages splits the fields into pages with the same title and
+// description. There's always at least one page.
+func newHelpPages(title, description string,
+	fields []*discordgo.MessageEmbedField) []*discordgo.MessageEmbed {
+
+	var newPage = func() *discordgo.MessageEmbed {
+		return &discordgo.MessageEmbed{
+			Title:       title,
+			Description: description,
+		}
+	}
+
+	var pages = []*discordgo.MessageEmbed{newPage()}
+	var length = len(title) + len(description)
+
+	for _, field := range fields {
+		var page = pages[len(pages)-1]
+		var size = len(field.Name) + len(field.Value)
+
+		if len(page.Fields) > 0 &&
+			(len(page.Fields) == helpPageFields || length+size > helpPageLength) {
+
+			page = newPage()
+			pages = append(pages, page)
+			length = len(title) + len(description)
+		}
+
+		page.Fields = append(page.Fields, field)
+		length += size
+	}
+
+	return pages
+}
+
+// CommandHelp generates the help embed of a single command or subcommand, given
+// its path, such as "debug goroutines". Commands that are hidden in the help
+// message for m are treated as unknown. m may be nil.
+func (ctx *Context) CommandHelp(m *discordgo.MessageCreate,
+	path ...string) (*discordgo.MessageEmbed, error) {
+
+	var prefix = ctx.helpPrefix(m)
+
+	cmd, sub, unknown := ctx.findPath(path)
+	if unknown >= 0 && cmd == nil {
+		return nil, newErrUnknownCommand(prefix, path[:unknown], path[unknown], sub)
+	}
+
+	// Words after the command are only reported once it's known not hidden.
+	var extra bool
+	if unknown >= 0 {
+		path, extra = path[:unknown], true
+	}
+
+	for s := sub; s != nil; s = s.parent {
+		if s.parent != nil && ctx.helpHidden(s.Flag, m) {
+			return nil, newErrUnknownCommand(prefix, nil, path[0], ctx.Subcommand)
+		}
+	}
+
+	switch {
+	case len(path) == 0:
+		return ctx.helpEmbed(m)[0], nil
+	case cmd == nil:
+		return ctx.subcommandPages(m, prefix, sub)[0], nil
+	}
+
+	if ctx.helpHidden(cmd.Flag, m) {
+		return nil, newErrUnknownCommand(
+			prefix, path[:len(path)-1], path[len(path)-1], sub)
+	}
+
+	var name = prefix + strings.Join(append(sub.Path(), cmd.Name()), " ")
+
+	if extra {
+		return nil, errors.New("Too many words after command: " + name)
+	}
+
+	var embed = &discordgo.MessageEmbed{
+		Title:       name,
+		Description: cmd.Description,
+	}
+
+	var field = func(name, value string) {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  name,
+			Value: value,
+		})
+	}
+
+	field("Usage", "`"+strings.Join(append([]string{name}, cmd.Usage()...), " ")+"`")
+
+	if len(cmd.Examples) > 0 {
+		var examples = make([]string, len(cmd.Examples))
+		for i, example := range cmd.Examples {
+			examples[i] = "`" + name + " " + example + "`"
+		}
+
+		field("Examples", strings.Join(examples, "\n"))
+	}
+
+	if len(cmd.Aliases) > 0 {
+		field("Aliases", strings.Join(cmd.Aliases, ", "))
+	}
+
+	if flags := flagNames(cmd.flags()); len(flags) > 0 {
+		field("Restrictions", strings.Join(flags, ", "))
+	}
+
+	if perms := PermissionNames(cmd.permissions()); len(perms) > 0 {
+		field("Permissions", strings.Join(perms, ", "))
+	}
+
+	if cmd.Cooldown != nil {
+		field("Cooldown", cooldownString(*cmd.Cooldown))
+	}
+
+	return embed, nil
+}
+
+// flagNames returns the human-readable restrictions of the flag.
+func flagNames(flag NameFlag) []string {
+	var names []string
+
+	for _, f := range []struct {
+		flag NameFlag
+		name string
+	}{
+		{AdminOnly, "Administrators only"},
+		{OwnerOnly, "Bot owners only"},
+		{GuildOnly, "Servers only"},
+		{DMOnly, "DMs only"},
+		{NSFWOnly, "NSFW channels only"},
+	} {
+		if flag.Is(f.flag) {
+			names = append(names, f.name)
+		}
+	}
+
+	return names
+}
+
+func cooldownString(c Cooldown) string {
+	var bucket string
+
+	switch c.Bucket {
+	case BucketUser:
+		bucket = " per user"
+	case BucketChannel:
+		bucket = " per channel"
+	case BucketGuild:
+		bucket = " per server"
+	}
+
+	return strconv.Itoa(c.Uses) + " uses every " + c.Per.String() + bucket
+}
+
+// SendHelp replies to m with the help embed pages, which the author of m can
+// turn with reactions, or with the help of a single command if a path is
+// given. It's meant to be used by a help command:
+//
+//    func (c *Commands) Help(m *discordgo.MessageCreate, command ...string) error {
+//        return c.Context.SendHelp(m, command...)
+//    }
+//
+func (ctx *Context) SendHelp(m *discordgo.MessageCreate, path ...string) error {
+	var inv = ctx.responses.current(m.ChannelID, m.ID)
+
+	if len(path) > 0 {
+		embed, err := ctx.CommandHelp(m, path...)
+		if err != nil {
+			return err
+		}
+
+		_, err = ctx.send(inv, m.ChannelID, embed)
+		return err
+	}
+
+	var pages = ctx.HelpEmbedFor(m)
+
+	msg, err := ctx.send(inv, m.ChannelID, pages[0])
+	if err != nil || len(pages) == 1 || m.Author == nil {
+		return err
+	}
+
+	ctx.helpPagers.add(msg.ID, &helpPager{
+		userID: m.Author.ID,
+		pages:  pages,
+	})
+
+	for _, emoji := range []string{helpPrevious, helpNext} {
+		if err := ctx.MessageReactionAdd(m.ChannelID, msg.ID, emoji); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// helpPager is a help message with pages that can be turned.
+type helpPager struct {
+	userID string
+	pages  []*discordgo.MessageEmbed
+	page   int
+}
+
+// helpPagers remembers the most recent help messages. It is safe for
+// concurrent use.
+type helpPagers struct {
+	mutex  sync.Mutex
+	pagers map[string]*helpPager // keyed by message ID
+	order  []string
+}
+
+func newHelpPagers() *helpPagers {
+	return &helpPagers{
+		pagers: map[string]*helpPager{},
+	}
+}
+
+func (h *helpPagers) add(messageID string, pager *helpPager) {
+	if h == nil {
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.pagers[messageID] = pager
+	h.order = append(h.order, messageID)
+
+	for len(h.order) > maxHelpPagers {
+		delete(h.pagers, h.order[0])
+		h.order = h.order[1:]
+	}
+}
+
+// turn turns the page of the message by delta for the user, returning the new
+// page if it changed.
+func (h *helpPagers) turn(messageID, userID string, delta int) (*discordgo.MessageEmbed, bool) {
+	if h == nil {
+		return nil, false
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	pager, ok := h.pagers[messageID]
+	if !ok || pager.userID != userID {
+		return nil, false
+	}
+
+	var page = pager.page + delta
+	if page < 0 || page >= len(pager.pages) {
+		return nil, false
+	}
+
+	pager.page = page
+	return pager.pages[page], true
+}
+
+// turnHelpPage turns the page of a help message if the reaction is an arrow.
+func (ctx *Context) turnHelpPage(r *discordgo.MessageReaction) error {
+	var delta int
+
+	switch r.Emoji.Name {
+	case helpPrevious, strings.TrimSuffix(helpPrevious, "\ufe0f"):
+		delta = -1
+	case helpNext, strings.TrimSuffix(helpNext, "\ufe0f"):
+		delta = 1
+	default:
+		return nil
+	}
+
+	page, ok := ctx.helpPagers.turn(r.MessageID, r.UserID, delta)
+	if !ok {
+		return nil
+	}
+
+	if _, err := ctx.ChannelMessageEditEmbed(r.ChannelID, r.MessageID, page); err != nil {
+		return err
+	}
+
+	// Let the user react again. This fails without Manage Messages, such as in
+	// direct messages, which is fine.
+	ctx.MessageReactionRemove(r.ChannelID, r.MessageID, r.Emoji.APIName(), r.UserID)
+	return nil
+}
@@ -0,0 +1,156 @@
+package rfrouter
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestHelpEmbed(t *testing.T) {
+	ctx, err := New(&discordgo.Session{}, &aclCommands{})
+	if err != nil {
+		t.Fatal("Failed to create new context:", err)
+	}
+
+	ctx.Name = "test"
+
+	if _, err := ctx.RegisterSubcommand(&musicCommands{}); err != nil {
+		t.Fatal("Failed to register subcommand:", err)
+	}
+
+	if _, err := ctx.RegisterSubcommand(&AーAuthSubcommand{}); err != nil {
+		t.Fatal("Failed to register subcommand:", err)
+	}
+
+	var pages = ctx.HelpEmbed()
+	if len(pages) != 2 {
+		t.Fatal("unexpected number of pages:", len(pages))
+	}
+
+	if pages[0].Title != "Help: test" || pages[0].Footer.Text != "Page 1/2" {
+		t.Fatal("unexpected first page:", pages[0].Title, pages[0].Footer.Text)
+	}
+
+	if len(pages[0].Fields) != 1 || pages[0].Fields[0].Name != "~ping" {
+		t.Fatal("unexpected fields on the first page:", pages[0].Fields)
+	}
+
+	if pages[1].Title != "~music (aliases: m)" || len(pages[1].Fields) != 2 {
+		t.Fatal("unexpected music page:", pages[1].Title, pages[1].Fields)
+	}
+
+	if pages[1].Fields[0].Name != "~music play" {
+		t.Fatal("unexpected music field:", pages[1].Fields[0].Name)
+	}
+
+	t.Run("split", func(t *testing.T) {
+		var fields []*discordgo.MessageEmbedField
+		for i := 0; i < helpPageFields*2+1; i++ {
+			fields = append(fields, &discordgo.MessageEmbedField{
+				Name:  "~cmd" + strconv.Itoa(i),
+				Value: "description",
+			})
+		}
+
+		pages := newHelpPages("title", "", fields)
+		if len(pages) != 3 || len(pages[2].Fields) != 1 {
+			t.Fatal("unexpected pages:", len(pages))
+		}
+
+		if pages := newHelpPages("title", "", nil); len(pages) != 1 {
+			t.Fatal("expected a page without any fields")
+		}
+	})
+
+	t.Run("command", func(t *testing.T) {
+		embed, err := ctx.CommandHelp(nil, "m", "play")
+		if err != nil {
+			t.Fatal("Failed to get command help:", err)
+		}
+
+		if embed.Title != "~music play" || embed.Fields[0].Value != "`~music play`" {
+			t.Fatal("unexpected command help:", embed.Title, embed.Fields[0].Value)
+		}
+
+		embed, err = ctx.CommandHelp(nil, "music")
+		if err != nil {
+			t.Fatal("Failed to get subcommand help:", err)
+		}
+
+		if embed.Title != "~music (aliases: m)" {
+			t.Fatal("unexpected subcommand help:", embed.Title)
+		}
+
+		_, err = ctx.CommandHelp(nil, "music", "plya")
+		if err == nil || err.Error() != "Unknown command: ~music plya, did you mean ~music play?" {
+			t.Fatal("unexpected error:", err)
+		}
+
+		_, err = ctx.CommandHelp(nil, "m", "play", "extra")
+		if err == nil || err.Error() != "Too many words after command: ~music play" {
+			t.Fatal("unexpected error:", err)
+		}
+
+		// Admin-only subcommands are hidden, so they're unknown.
+		if _, err := ctx.CommandHelp(nil, "d", "die"); err == nil {
+			t.Fatal("expected an error for a hidden command")
+		}
+	})
+}
+
+func TestCommandHelpDetails(t *testing.T) {
+	ctx, err := New(&discordgo.Session{}, &flagCommands{})
+	if err != nil {
+		t.Fatal("Failed to create new context:", err)
+	}
+
+	embed, err := ctx.CommandHelp(nil, "guild")
+	if err != nil {
+		t.Fatal("Failed to get command help:", err)
+	}
+
+	if len(embed.Fields) != 2 || embed.Fields[1].Value != "Servers only" {
+		t.Fatal("unexpected fields:", embed.Fields)
+	}
+
+	embed, err = ctx.CommandHelp(nil, "ban")
+	if err != nil {
+		t.Fatal("Failed to get command help:", err)
+	}
+
+	if len(embed.Fields) != 2 || embed.Fields[1].Value != "Ban Members" {
+		t.Fatal("unexpected fields:", embed.Fields)
+	}
+}
+
+func TestHelpPagers(t *testing.T) {
+	var pagers = newHelpPagers()
+	var pages = []*discordgo.MessageEmbed{{Title: "1"}, {Title: "2"}}
+
+	pagers.add("message", &helpPager{userID: "user", pages: pages})
+
+	if _, ok := pagers.turn("message", "user", -1); ok {
+		t.Fatal("unexpected turn before the first page")
+	}
+
+	if _, ok := pagers.turn("message", "other", 1); ok {
+		t.Fatal("unexpected turn by another user")
+	}
+
+	if page, ok := pagers.turn("message", "user", 1); !ok || page.Title != "2" {
+		t.Fatal("unexpected page:", page)
+	}
+
+	if _, ok := pagers.turn("message", "user", 1); ok {
+		t.Fatal("unexpected turn after the last page")
+	}
+
+	for i := 0; i < maxHelpPagers; i++ {
+		pagers.add(strconv.Itoa(i), &helpPager{userID: "user", pages: pages})
+	}
+
+	if _, ok := pagers.turn("message", "user", -1); ok {
+		t.Fatal("expected the oldest help message to be forgotten")
+	}
+}
@@ -0,0 +1,62 @@
+package rfrouter
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// readJSONFile decodes the JSON file at path into v. A missing file is not an
+// error, and v is left untouched.
+func readJSONFile(path string, v interface{}) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return errors.Wrap(err, "Failed to read "+path)
+	}
+
+	if err := json.Unmarshal(b, v); err != nil {
+		return errors.Wrap(err, "Failed to decode "+path)
+	}
+
+	return nil
+}
+
+// writeJSONFile encodes v into the file at path. The file is replaced
+// atomically, so it's never left half-written.
+func writeJSONFile(path string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return errors.Wrap(err, "Failed to encode "+path)
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return errors.Wrap(err, "Failed to create a temporary file")
+	}
+
+	// Clean up the temporary file if anything fails. This is a no-op after
+	// the rename.
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return errors.Wrap(err, "Failed to write "+path)
+	}
+
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "Failed to write "+path)
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		return errors.Wrap(err, "Failed to replace "+path)
+	}
+
+	return nil
+}
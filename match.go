@@ -0,0 +1,122 @@
+package rfrouter
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MatchMode is how the command names that users type are compared against the
+// names and aliases of commands and subcommands. Raw commands and subcommands
+// are always matched exactly.
+type MatchMode uint8
+
+const (
+	// MatchExact compares names as they are. As names are lower-cased unless
+	// Raw, commands must be typed in lower case.
+	MatchExact MatchMode = iota
+	// MatchFold compares names case-insensitively, like strings.EqualFold.
+	MatchFold
+	// MatchNFKC normalizes names into Unicode NFKC before comparing them
+	// case-insensitively, so full-width characters typed with CJK input
+	// methods, such as "ｈｅｌｐ", also match.
+	MatchNFKC
+)
+
+func (mode MatchMode) equal(name, input string) bool {
+	switch mode {
+	case MatchFold:
+		return strings.EqualFold(name, input)
+	case MatchNFKC:
+		return strings.EqualFold(norm.NFKC.String(name), norm.NFKC.String(input))
+	default:
+		return name == input
+	}
+}
+
+// fold returns the form of s that's compared in suggestions.
+func (mode MatchMode) fold(s string) string {
+	switch mode {
+	case MatchFold:
+		return strings.ToLower(s)
+	case MatchNFKC:
+		return strings.ToLower(norm.NFKC.String(s))
+	default:
+		return s
+	}
+}
+
+// matchName returns true if input matches the name or any of the aliases.
+func (mode MatchMode) matchName(input string, name string, aliases []string) bool {
+	if mode.equal(name, input) {
+		return true
+	}
+
+	for _, alias := range aliases {
+		if mode.equal(alias, input) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lookup returns the command or the child subcommand that name matches. Exact
+// matches are preferred, so a Raw "GC" command isn't shadowed by a "gc" one.
+func (sub *Subcommand) lookup(mode MatchMode, name string) (*CommandContext, *Subcommand) {
+	if cmd := sub.findMessageCommand(name); cmd != nil {
+		return cmd, nil
+	}
+
+	if child := sub.findSubcommand(name); child != nil {
+		return nil, child
+	}
+
+	if mode == MatchExact {
+		return nil, nil
+	}
+
+	for _, cmd := range sub.Commands {
+		if cmd.event != typeMessageCreate || cmd.Flag.Is(Raw) {
+			continue
+		}
+
+		if mode.matchName(name, cmd.name, cmd.Aliases) {
+			return cmd, nil
+		}
+	}
+
+	for _, child := range sub.Subcommands {
+		if !child.Flag.Is(Raw) && mode.matchName(name, child.name, child.Aliases) {
+			return nil, child
+		}
+	}
+
+	return nil, nil
+}
+
+// findPath walks down the words, which may be names or aliases, to a command or
+// a subcommand. For a command, the subcommand it belongs to is also returned.
+// If a word is unknown, its index is returned along with the subcommand it was
+// looked up in, otherwise -1. If words follow a command, the command is
+// returned along with the index of the first of them.
+func (ctx *Context) findPath(words []string) (*CommandContext, *Subcommand, int) {
+	var sub = ctx.Subcommand
+
+	for i, word := range words {
+		cmd, child := sub.lookup(ctx.MatchMode, word)
+
+		switch {
+		case cmd != nil && i == len(words)-1:
+			return cmd, sub, -1
+		case cmd != nil && child == nil:
+			return cmd, sub, i + 1
+		case child != nil:
+			sub = child
+		default:
+			return nil, sub, i
+		}
+	}
+
+	return nil, sub, -1
+}
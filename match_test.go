@@ -0,0 +1,91 @@
+package rfrouter
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type matchCommands struct {
+	Ctx  *Context
+	Last string
+}
+
+func (m *matchCommands) Help(_ *discordgo.MessageCreate) error {
+	m.Last = "help"
+	return nil
+}
+
+func (m *matchCommands) RーGC(_ *discordgo.MessageCreate) error {
+	m.Last = "GC"
+	return nil
+}
+
+func (m *matchCommands) Gc(_ *discordgo.MessageCreate) error {
+	m.Last = "gc"
+	return nil
+}
+
+func TestMatchMode(t *testing.T) {
+	var cmds = &matchCommands{}
+
+	ctx, err := New(&discordgo.Session{}, cmds)
+	if err != nil {
+		t.Fatal("Failed to create new context:", err)
+	}
+
+	var tests = []struct {
+		mode    MatchMode
+		content string
+		called  string // empty if unknown
+	}{
+		{MatchExact, "~help", "help"},
+		{MatchExact, "~Help", ""},
+		{MatchExact, "~ｈｅｌｐ", ""},
+		{MatchExact, "~GC", "GC"},
+		{MatchFold, "~HELP", "help"},
+		{MatchFold, "~ｈｅｌｐ", ""},
+		{MatchFold, "~GC", "GC"},
+		{MatchFold, "~gc", "gc"},
+		{MatchFold, "~Gc", "gc"},
+		{MatchNFKC, "~ｈｅｌｐ", "help"},
+		{MatchNFKC, "~ＨＥＬＰ", "help"},
+		{MatchNFKC, "~ＧＣ", "gc"},
+	}
+
+	for _, test := range tests {
+		ctx.MatchMode = test.mode
+		cmds.Last = ""
+
+		err := ctx.Call(&discordgo.MessageCreate{
+			Message: &discordgo.Message{Content: test.content},
+		})
+
+		if test.called == "" {
+			if _, ok := err.(*ErrUnknownCommand); !ok {
+				t.Fatal("expected unknown command for", test, "got", err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatal("unexpected error for", test, err)
+		}
+
+		if cmds.Last != test.called {
+			t.Fatal("unexpected command called for", test, cmds.Last)
+		}
+	}
+
+	t.Run("suggestions", func(t *testing.T) {
+		ctx.MatchMode = MatchFold
+
+		err := ctx.Call(&discordgo.MessageCreate{
+			Message: &discordgo.Message{Content: "~HEPL"},
+		})
+
+		if s := err.Error(); s != "Unknown command: ~HEPL, did you mean ~help?" {
+			t.Fatal("unexpected error:", s)
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package rfrouter
+
+import (
+	"reflect"
+	"runtime/debug"
+)
+
+// HandlerFunc handles a single invocation of a command. ev is the event that
+// triggered it, and args are the parsed arguments following the event. For
+// events other than Message Create, args is always empty.
+type HandlerFunc func(ev interface{}, cmd *CommandContext, args []reflect.Value) error
+
+// Middleware wraps a HandlerFunc. A middleware could stop the invocation by
+// returning an error without calling next.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Use adds middlewares around every command of this subcommand and its
+// children. Middlewares of parents run before those of their children, and
+// middlewares added first run first.
+func (sub *Subcommand) Use(middlewares ...Middleware) {
+	sub.middlewares = append(sub.middlewares, middlewares...)
+}
+
+// invoke calls the command with all middlewares of its subcommands. Panics are
+// recovered into an *ErrPanic unless NoRecover is true.
+func (ctx *Context) invoke(
+	cmd *CommandContext, ev interface{}, args []reflect.Value) (err error) {
+
+	if !ctx.NoRecover {
+		defer recoverPanic(cmd, &err)
+	}
+
+	var handler HandlerFunc = func(
+		ev interface{}, cmd *CommandContext, args []reflect.Value) error {
+
+		return callWith(cmd.value, ev, args...)
+	}
+
+	// Wrap from the innermost middleware to the outermost one.
+	for sub := cmd.parent; sub != nil; sub = sub.parent {
+		for i := len(sub.middlewares) - 1; i >= 0; i-- {
+			handler = sub.middlewares[i](handler)
+		}
+	}
+
+	return handler(ev, cmd, args)
+}
+
+// recoverPanic recovers a panic into an *ErrPanic in err. It has to be deferred
+// directly.
+func recoverPanic(cmd *CommandContext, err *error) {
+	if v := recover(); v != nil {
+		*err = &ErrPanic{Command: cmd, Value: v, Stack: debug.Stack()}
+	}
+}
@@ -0,0 +1,127 @@
+package rfrouter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/pkg/errors"
+)
+
+type middlewareCommands struct {
+	Ctx    *Context
+	Called []string
+}
+
+func (m *middlewareCommands) Echo(_ *discordgo.MessageCreate, arg string) error {
+	m.Called = append(m.Called, "echo "+arg)
+	return nil
+}
+
+func (m *middlewareCommands) Typing(_ *discordgo.TypingStart) error {
+	m.Called = append(m.Called, "typing")
+	return nil
+}
+
+func TestMiddleware(t *testing.T) {
+	var given = &middlewareCommands{}
+
+	ctx, err := New(&discordgo.Session{}, given)
+	if err != nil {
+		t.Fatal("Failed to create new context:", err)
+	}
+
+	var trace []string
+
+	var record = func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ev interface{},
+				cmd *CommandContext, args []reflect.Value) error {
+
+				trace = append(trace, name+" "+cmd.Name())
+				return next(ev, cmd, args)
+			}
+		}
+	}
+
+	ctx.Use(record("first"), record("second"))
+
+	t.Run("message create", func(t *testing.T) {
+		trace = nil
+
+		err := ctx.Call(&discordgo.MessageCreate{
+			Message: &discordgo.Message{Content: "~echo hi"},
+		})
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		expect := []string{"first echo", "second echo"}
+		if !reflect.DeepEqual(trace, expect) {
+			t.Fatal("unexpected middleware trace:", trace)
+		}
+	})
+
+	t.Run("other events", func(t *testing.T) {
+		trace = nil
+
+		if err := ctx.Call(&discordgo.TypingStart{}); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		expect := []string{"first typing", "second typing"}
+		if !reflect.DeepEqual(trace, expect) {
+			t.Fatal("unexpected middleware trace:", trace)
+		}
+	})
+
+	t.Run("subcommand", func(t *testing.T) {
+		trace = nil
+
+		sub, err := ctx.RegisterSubcommand(&middlewareCommands{})
+		if err != nil {
+			t.Fatal("Failed to register subcommand:", err)
+		}
+
+		sub.Use(record("sub"))
+
+		err = ctx.Call(&discordgo.MessageCreate{
+			Message: &discordgo.Message{Content: "~middlewarecommands echo hi"},
+		})
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		expect := []string{"first echo", "second echo", "sub echo"}
+		if !reflect.DeepEqual(trace, expect) {
+			t.Fatal("unexpected middleware trace:", trace)
+		}
+	})
+
+	t.Run("short circuit", func(t *testing.T) {
+		given.Called = nil
+
+		ctx.Use(func(next HandlerFunc) HandlerFunc {
+			return func(ev interface{},
+				cmd *CommandContext, args []reflect.Value) error {
+
+				if len(args) > 0 && args[0].String() == "no" {
+					return errors.New("blocked")
+				}
+
+				return next(ev, cmd, args)
+			}
+		})
+
+		err := ctx.Call(&discordgo.MessageCreate{
+			Message: &discordgo.Message{Content: "~echo no"},
+		})
+		if err == nil || err.Error() != "blocked" {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if len(given.Called) > 0 {
+			t.Fatal("command called despite being blocked:", given.Called)
+		}
+	})
+}
@@ -13,6 +13,10 @@ const (
 
 	Raw       // R
 	AdminOnly // A
+	GuildOnly // G
+	DMOnly    // D
+	NSFWOnly  // N
+	OwnerOnly // O
 )
 
 func ParseFlag(name string) (NameFlag, string) {
@@ -29,6 +33,14 @@ func ParseFlag(name string) (NameFlag, string) {
 			f |= Raw
 		case 'A':
 			f |= AdminOnly
+		case 'G':
+			f |= GuildOnly
+		case 'D':
+			f |= DMOnly
+		case 'N':
+			f |= NSFWOnly
+		case 'O':
+			f |= OwnerOnly
 		}
 	}
 
@@ -15,6 +15,12 @@ func TestNameFlag(t *testing.T) {
 	}, {
 		Name:   "RAーGC",
 		Expect: Raw | AdminOnly,
+	}, {
+		Name:   "GNーPurge",
+		Expect: GuildOnly | NSFWOnly,
+	}, {
+		Name:   "DーSecret",
+		Expect: DMOnly,
 	}}
 
 	for _, entry := range entries {
@@ -0,0 +1,35 @@
+package rfrouter
+
+import (
+	"github.com/pkg/errors"
+)
+
+// IsOwner returns true if the user ID is one of the bot's owners.
+func (ctx *Context) IsOwner(userID string) bool {
+	return userID != "" && contains(ctx.Owners, userID)
+}
+
+// FetchOwners adds the owner of the bot's application into Owners. As Owners
+// isn't guarded, it should be called before Start.
+func (ctx *Context) FetchOwners() error {
+	app, err := ctx.Session.Application("@me")
+	if err != nil {
+		return errors.Wrap(err, "Failed to get the application")
+	}
+
+	if app.Owner != nil && !ctx.IsOwner(app.Owner.ID) {
+		ctx.Owners = append(ctx.Owners, app.Owner.ID)
+	}
+
+	return nil
+}
+
+// checkOwner checks that the user is an owner if the command or any of its
+// subcommands is owner-only.
+func (ctx *Context) checkOwner(cmd *CommandContext, ev interface{}) error {
+	if cmd.flags().Is(OwnerOnly) && !ctx.IsOwner(reflectUserID(ev)) {
+		return &ErrForbidden{Command: cmd, Flag: OwnerOnly}
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package rfrouter
+
+import (
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/pkg/errors"
+)
+
+// PermissionRequirer is optionally used to set the Discord permissions
+// required for all commands in a subcommand, both by the user and the bot.
+type PermissionRequirer interface {
+	RequiredPermissions() int
+}
+
+// CommandPermissioner is optionally used to set the Discord permissions
+// required for commands. The returned map's keys are command names, such as
+// "ban".
+type CommandPermissioner interface {
+	CommandPermissions() map[string]int
+}
+
+// permissionNames contains the human-readable names of each permission bit, in
+// the order Discord lists them.
+var permissionNames = []struct {
+	bit  int
+	name string
+}{
+	{discordgo.PermissionAdministrator, "Administrator"},
+	{discordgo.PermissionViewAuditLogs, "View Audit Log"},
+	{discordgo.PermissionManageServer, "Manage Server"},
+	{discordgo.PermissionManageRoles, "Manage Roles"},
+	{discordgo.PermissionManageChannels, "Manage Channels"},
+	{discordgo.PermissionKickMembers, "Kick Members"},
+	{discordgo.PermissionBanMembers, "Ban Members"},
+	{discordgo.PermissionCreateInstantInvite, "Create Invite"},
+	{discordgo.PermissionChangeNickname, "Change Nickname"},
+	{discordgo.PermissionManageNicknames, "Manage Nicknames"},
+	{discordgo.PermissionManageEmojis, "Manage Emojis"},
+	{discordgo.PermissionManageWebhooks, "Manage Webhooks"},
+	{discordgo.PermissionReadMessages, "Read Messages"},
+	{discordgo.PermissionSendMessages, "Send Messages"},
+	{discordgo.PermissionSendTTSMessages, "Send TTS Messages"},
+	{discordgo.PermissionManageMessages, "Manage Messages"},
+	{discordgo.PermissionEmbedLinks, "Embed Links"},
+	{discordgo.PermissionAttachFiles, "Attach Files"},
+	{discordgo.PermissionReadMessageHistory, "Read Message History"},
+	{discordgo.PermissionMentionEveryone, "Mention Everyone"},
+	{discordgo.PermissionUseExternalEmojis, "Use External Emojis"},
+	{discordgo.PermissionAddReactions, "Add Reactions"},
+	{discordgo.PermissionVoiceConnect, "Connect"},
+	{discordgo.PermissionVoiceSpeak, "Speak"},
+	{discordgo.PermissionVoiceMuteMembers, "Mute Members"},
+	{discordgo.PermissionVoiceDeafenMembers, "Deafen Members"},
+	{discordgo.PermissionVoiceMoveMembers, "Move Members"},
+	{discordgo.PermissionVoiceUseVAD, "Use Voice Activity"},
+	{discordgo.PermissionVoicePrioritySpeaker, "Priority Speaker"},
+}
+
+// PermissionNames returns the human-readable names of all permissions set in
+// perms, such as "Ban Members".
+func PermissionNames(perms int) []string {
+	var names []string
+
+	for _, p := range permissionNames {
+		if perms&p.bit != 0 {
+			names = append(names, p.name)
+		}
+	}
+
+	return names
+}
+
+// ErrMissingPermissions is returned when either the user or the bot doesn't
+// have the permissions that a command requires in the channel.
+type ErrMissingPermissions struct {
+	Command *CommandContext
+
+	// Missing contains the permission bits that are missing.
+	Missing int
+	// Bot is true if the bot is missing the permissions, rather than the user.
+	Bot bool
+}
+
+func (err *ErrMissingPermissions) Error() string {
+	var who = "You are"
+	if err.Bot {
+		who = "I am"
+	}
+
+	return who + " missing the following permissions: " +
+		strings.Join(PermissionNames(err.Missing), ", ") + "."
+}
+
+// permissions returns the permissions required by the command and all of its
+// subcommands.
+func (cctx *CommandContext) permissions() int {
+	var perms = cctx.Permissions
+	for sub := cctx.parent; sub != nil; sub = sub.parent {
+		perms |= sub.Permissions
+	}
+
+	return perms
+}
+
+// checkPermissions checks that both the user and the bot have the permissions
+// required by the command in the event's channel. Commands requiring
+// permissions can only be used in guilds.
+func (ctx *Context) checkPermissions(cmd *CommandContext, ev interface{}) error {
+	var required = cmd.permissions()
+	if required == 0 {
+		return nil
+	}
+
+	if reflectGuildID(ev) == "" {
+		return &ErrGuildOnly{cmd}
+	}
+
+	var channelID = reflectChannelID(ev)
+
+	missing, err := ctx.missingPermissions(channelID, reflectUserID(ev), required)
+	if err != nil {
+		return err
+	}
+
+	if missing != 0 {
+		return &ErrMissingPermissions{Command: cmd, Missing: missing}
+	}
+
+	// The bot's own user is only known once connected.
+	if ctx.Session.State == nil || ctx.Session.State.User == nil {
+		return nil
+	}
+
+	missing, err = ctx.missingPermissions(
+		channelID, ctx.Session.State.User.ID, required)
+	if err != nil {
+		return err
+	}
+
+	if missing != 0 {
+		return &ErrMissingPermissions{Command: cmd, Missing: missing, Bot: true}
+	}
+
+	return nil
+}
+
+// missingPermissions returns the bits in required that the user doesn't have in
+// the channel.
+func (ctx *Context) missingPermissions(
+	channelID, userID string, required int) (int, error) {
+
+	perms, err := ctx.UserPermissions(channelID, userID)
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to get permissions")
+	}
+
+	return required &^ perms, nil
+}
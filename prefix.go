@@ -0,0 +1,284 @@
+package rfrouter
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/pkg/errors"
+)
+
+// PrefixStore stores the prefixes of each guild.
+type PrefixStore interface {
+	// Prefixes returns the guild's prefixes, or nil if it has none set.
+	Prefixes(guildID string) ([]string, error)
+	// SetPrefixes sets the guild's prefixes. Setting no prefixes removes them,
+	// so the defaults are used again.
+	SetPrefixes(guildID string, prefixes []string) error
+}
+
+// StorePrefixFunc returns a function for Context's PrefixFunc that uses the
+// prefixes in the store for each guild. The default prefixes are used in
+// direct messages, for guilds without any prefixes set, and when the store
+// fails.
+func StorePrefixFunc(
+	store PrefixStore, defaults ...string) func(*discordgo.MessageCreate) []string {
+
+	return func(m *discordgo.MessageCreate) []string {
+		if m.GuildID == "" {
+			return defaults
+		}
+
+		prefixes, err := store.Prefixes(m.GuildID)
+		if err != nil || len(prefixes) == 0 {
+			return defaults
+		}
+
+		return prefixes
+	}
+}
+
+// prefixes returns all prefixes that can be used for the message.
+func (ctx *Context) prefixes(m *discordgo.MessageCreate) []string {
+	if ctx.PrefixFunc == nil {
+		return []string{ctx.Prefix}
+	}
+
+	return ctx.PrefixFunc(m)
+}
+
+// matchPrefix returns the longest prefix that the message starts with. A
+// mention of the bot is used over any other prefix.
+func (ctx *Context) matchPrefix(m *discordgo.MessageCreate) (string, bool) {
+	if prefix, ok := ctx.mentionPrefix(m.Content); ok {
+		return prefix, true
+	}
+
+	var prefix string
+	var found bool
+
+	for _, p := range ctx.prefixes(m) {
+		// An empty prefix would match every message. Only Prefix may be empty,
+		// for bots that take commands without one.
+		if p == "" && ctx.PrefixFunc != nil {
+			continue
+		}
+
+		if strings.HasPrefix(m.Content, p) && (!found || len(p) > len(prefix)) {
+			prefix = p
+			found = true
+		}
+	}
+
+	return prefix, found
+}
+
+// mentionPrefix returns the mention of the bot that content starts with,
+// including the whitespace after it, if MentionPrefix is true.
+func (ctx *Context) mentionPrefix(content string) (string, bool) {
+	if !ctx.MentionPrefix || ctx.Session == nil || ctx.State == nil || ctx.State.User == nil {
+		return "", false
+	}
+
+	var id = ctx.State.User.ID
+
+	for _, mention := range []string{"<@" + id + ">", "<@!" + id + ">"} {
+		if !strings.HasPrefix(content, mention) {
+			continue
+		}
+
+		rest := strings.TrimLeftFunc(content[len(mention):], unicode.IsSpace)
+		return content[:len(content)-len(rest)], true
+	}
+
+	return "", false
+}
+
+// replyMention replies to a message that only mentions the bot.
+func (ctx *Context) replyMention(m *discordgo.MessageCreate) error {
+	var reply string
+
+	if ctx.MentionReply != nil {
+		reply = ctx.MentionReply(m)
+	} else {
+		reply = "My prefixes are " + quotePrefixes(ctx.prefixes(m)) + "."
+	}
+
+	if reply == "" {
+		return nil
+	}
+
+	return ctx.Send(m.ChannelID, reply)
+}
+
+// MemoryPrefixStore is a PrefixStore that keeps prefixes in memory. It is safe
+// for concurrent use.
+type MemoryPrefixStore struct {
+	mutex    sync.RWMutex
+	prefixes map[string][]string
+}
+
+var _ PrefixStore = (*MemoryPrefixStore)(nil)
+
+func NewMemoryPrefixStore() *MemoryPrefixStore {
+	return &MemoryPrefixStore{
+		prefixes: map[string][]string{},
+	}
+}
+
+func (s *MemoryPrefixStore) Prefixes(guildID string) ([]string, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.prefixes[guildID], nil
+}
+
+func (s *MemoryPrefixStore) SetPrefixes(guildID string, prefixes []string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	setPrefixes(s.prefixes, guildID, prefixes)
+	return nil
+}
+
+// setPrefixes sets the guild's prefixes in the map.
+func setPrefixes(guilds map[string][]string, guildID string, prefixes []string) {
+	if len(prefixes) == 0 {
+		delete(guilds, guildID)
+		return
+	}
+
+	guilds[guildID] = append([]string(nil), prefixes...)
+}
+
+// JSONPrefixStore is a PrefixStore that keeps prefixes in memory, saving them
+// into a JSON file on every change. It is safe for concurrent use.
+type JSONPrefixStore struct {
+	MemoryPrefixStore
+	path string
+}
+
+var _ PrefixStore = (*JSONPrefixStore)(nil)
+
+// NewJSONPrefixStore creates a store that loads from and saves into the JSON
+// file at path. The file is created on the first change if it doesn't exist.
+func NewJSONPrefixStore(path string) (*JSONPrefixStore, error) {
+	s := &JSONPrefixStore{
+		MemoryPrefixStore: *NewMemoryPrefixStore(),
+		path:              path,
+	}
+
+	if err := readJSONFile(path, &s.prefixes); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *JSONPrefixStore) SetPrefixes(guildID string, prefixes []string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// The prefixes are only changed in memory once they're saved.
+	var guilds = make(map[string][]string, len(s.prefixes)+1)
+	for id, p := range s.prefixes {
+		guilds[id] = p
+	}
+
+	setPrefixes(guilds, guildID, prefixes)
+
+	if err := writeJSONFile(s.path, guilds); err != nil {
+		return err
+	}
+
+	s.prefixes = guilds
+	return nil
+}
+
+// PrefixCommands is a subcommand named "prefix" that lets guild admins view and
+// change the guild's prefixes. Context's PrefixFunc should be set to use the
+// same store, e.g. with StorePrefixFunc. Changing the prefixes requires the
+// Manage Server permission.
+//
+//    ctx.PrefixFunc = rfrouter.StorePrefixFunc(store, "~")
+//    ctx.RegisterSubcommand(&rfrouter.PrefixCommands{Store: store})
+//
+type PrefixCommands struct {
+	Context *Context
+	Store   PrefixStore
+}
+
+func (p *PrefixCommands) Name() string {
+	return "prefix"
+}
+
+func (p *PrefixCommands) Description() string {
+	return "view or change the server's prefixes"
+}
+
+func (p *PrefixCommands) CommandDescriptions() map[string]string {
+	return map[string]string{
+		"get":   "show the prefixes",
+		"set":   "replace the server's prefixes",
+		"reset": "reset the server's prefixes to the defaults",
+	}
+}
+
+func (p *PrefixCommands) CommandExamples() map[string][]string {
+	return map[string][]string{
+		"set": {"!", "! ?"},
+	}
+}
+
+func (p *PrefixCommands) CommandPermissions() map[string]int {
+	return map[string]int{
+		"set":   discordgo.PermissionManageServer,
+		"reset": discordgo.PermissionManageServer,
+	}
+}
+
+// Get replies with the prefixes that can be used.
+func (p *PrefixCommands) Get(m *discordgo.MessageCreate) error {
+	return p.Context.Send(m.ChannelID,
+		"Prefixes: "+quotePrefixes(p.Context.prefixes(m)))
+}
+
+// Set replaces the guild's prefixes.
+func (p *PrefixCommands) Set(m *discordgo.MessageCreate, prefixes ...string) error {
+	if len(prefixes) == 0 {
+		return errors.New("No prefixes given")
+	}
+
+	for _, prefix := range prefixes {
+		if strings.TrimSpace(prefix) == "" {
+			return errors.New("Prefixes can't be empty")
+		}
+	}
+
+	if err := p.Store.SetPrefixes(m.GuildID, prefixes); err != nil {
+		return errors.Wrap(err, "Failed to set prefixes")
+	}
+
+	return p.Context.Send(m.ChannelID,
+		"Prefixes set to "+quotePrefixes(prefixes))
+}
+
+// Reset removes the guild's prefixes, so the defaults are used again.
+func (p *PrefixCommands) Reset(m *discordgo.MessageCreate) error {
+	if err := p.Store.SetPrefixes(m.GuildID, nil); err != nil {
+		return errors.Wrap(err, "Failed to reset prefixes")
+	}
+
+	return p.Context.Send(m.ChannelID, "Prefixes reset to "+
+		quotePrefixes(p.Context.prefixes(m)))
+}
+
+func quotePrefixes(prefixes []string) string {
+	var quoted = make([]string, len(prefixes))
+	for i, prefix := range prefixes {
+		quoted[i] = "`" + prefix + "`"
+	}
+
+	return strings.Join(quoted, ", ")
+}
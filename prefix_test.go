@@ -0,0 +1,222 @@
+package rfrouter
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type prefixCommands struct {
+	Ctx *Context
+}
+
+func (p *prefixCommands) Ping(_ *discordgo.MessageCreate) error {
+	return nil
+}
+
+func TestPrefixFunc(t *testing.T) {
+	ctx, err := New(&discordgo.Session{}, &prefixCommands{})
+	if err != nil {
+		t.Fatal("Failed to create new context:", err)
+	}
+
+	var store = NewMemoryPrefixStore()
+	if err := store.SetPrefixes("guild", []string{"!", "!!"}); err != nil {
+		t.Fatal("Failed to set prefixes:", err)
+	}
+
+	ctx.PrefixFunc = StorePrefixFunc(store, "~")
+
+	var message = func(content, guildID string) *discordgo.MessageCreate {
+		return &discordgo.MessageCreate{
+			Message: &discordgo.Message{
+				Content: content,
+				GuildID: guildID,
+			},
+		}
+	}
+
+	for _, test := range []struct {
+		content, guildID string
+	}{
+		{"~ping", ""},
+		{"~ping", "other"},
+		{"!ping", "guild"},
+		{"!!ping", "guild"},
+	} {
+		if err := ctx.Call(message(test.content, test.guildID)); err != nil {
+			t.Fatal("unexpected error for", test, err)
+		}
+	}
+
+	// The default prefix doesn't work in guilds with their own.
+	if err := ctx.Call(message("~ping", "guild")); err != nil {
+		t.Fatal("unexpected error for an unmatched prefix:", err)
+	}
+
+	t.Run("matched prefix", func(t *testing.T) {
+		unknown, ok := ctx.Call(message("!!pong", "guild")).(*ErrUnknownCommand)
+		if !ok {
+			t.Fatal("expected unknown command error")
+		}
+
+		if unknown.Prefix != "!!" {
+			t.Fatal("unexpected prefix:", unknown.Prefix)
+		}
+
+		if s := unknown.Error(); s != "Unknown command: !!pong, did you mean !!ping?" {
+			t.Fatal("unexpected error:", s)
+		}
+	})
+
+	t.Run("empty prefixes", func(t *testing.T) {
+		var commands = &PrefixCommands{Context: ctx, Store: store}
+
+		for _, prefix := range []string{"", " \n"} {
+			if err := commands.Set(message("", "guild"), "?", prefix); err == nil {
+				t.Fatalf("expected an error for prefix %q", prefix)
+			}
+		}
+
+		if err := store.SetPrefixes("empty", []string{""}); err != nil {
+			t.Fatal("Failed to set prefixes:", err)
+		}
+
+		if err := ctx.Call(message("good morning", "empty")); err != nil {
+			t.Fatal("unexpected error for a message without a prefix:", err)
+		}
+	})
+
+	t.Run("help", func(t *testing.T) {
+		var help = ctx.HelpFor(message("!help", "guild"))
+		if !strings.Contains(help, "      !ping") {
+			t.Fatal("unexpected help:\n" + help)
+		}
+
+		help = ctx.HelpFor(message("~help", ""))
+		if !strings.Contains(help, "      ~ping") {
+			t.Fatal("unexpected help in DM:\n" + help)
+		}
+	})
+}
+
+func TestJSONPrefixStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rfrouter")
+	if err != nil {
+		t.Fatal("Failed to create temporary directory:", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var path = filepath.Join(dir, "prefixes.json")
+
+	s, err := NewJSONPrefixStore(path)
+	if err != nil {
+		t.Fatal("Failed to create store:", err)
+	}
+
+	if err := s.SetPrefixes("a", []string{"!", "?"}); err != nil {
+		t.Fatal("Failed to set prefixes:", err)
+	}
+
+	if err := s.SetPrefixes("b", []string{"$"}); err != nil {
+		t.Fatal("Failed to set prefixes:", err)
+	}
+
+	if err := s.SetPrefixes("b", nil); err != nil {
+		t.Fatal("Failed to reset prefixes:", err)
+	}
+
+	// Load the saved file into a new store.
+	s, err = NewJSONPrefixStore(path)
+	if err != nil {
+		t.Fatal("Failed to load store:", err)
+	}
+
+	if p, _ := s.Prefixes("a"); !reflect.DeepEqual(p, []string{"!", "?"}) {
+		t.Fatal("unexpected prefixes:", p)
+	}
+
+	if p, _ := s.Prefixes("b"); p != nil {
+		t.Fatal("unexpected prefixes after reset:", p)
+	}
+
+	// Prefixes that fail to save aren't set.
+	if err := os.Remove(path); err != nil {
+		t.Fatal("Failed to remove file:", err)
+	}
+
+	if err := os.Mkdir(path, 0755); err != nil {
+		t.Fatal("Failed to create directory:", err)
+	}
+
+	if err := s.SetPrefixes("a", []string{"$"}); err == nil {
+		t.Fatal("expected an error saving into a directory")
+	}
+
+	if p, _ := s.Prefixes("a"); !reflect.DeepEqual(p, []string{"!", "?"}) {
+		t.Fatal("unexpected prefixes after failing to save:", p)
+	}
+}
+
+func TestMentionPrefix(t *testing.T) {
+	var state = discordgo.NewState()
+	state.User = &discordgo.User{ID: "bot"}
+
+	ctx, err := New(&discordgo.Session{State: state}, &prefixCommands{})
+	if err != nil {
+		t.Fatal("Failed to create new context:", err)
+	}
+
+	var call = func(content string) error {
+		return ctx.Call(&discordgo.MessageCreate{
+			Message: &discordgo.Message{Content: content},
+		})
+	}
+
+	// Mentions are ignored until enabled.
+	if err := call("<@bot> pong"); err != nil {
+		t.Fatal("unexpected error with mentions disabled:", err)
+	}
+
+	ctx.MentionPrefix = true
+
+	for _, content := range []string{"~ping", "<@bot> ping", "<@!bot>ping", "<@bot>\n ping"} {
+		if err := call(content); err != nil {
+			t.Fatal("unexpected error for", content, err)
+		}
+	}
+
+	unknown, ok := call("<@!bot> pong").(*ErrUnknownCommand)
+	if !ok {
+		t.Fatal("expected unknown command error")
+	}
+
+	if unknown.Prefix != "<@!bot> " {
+		t.Fatal("unexpected prefix:", unknown.Prefix)
+	}
+
+	t.Run("bare mention", func(t *testing.T) {
+		var replied int
+		ctx.MentionReply = func(*discordgo.MessageCreate) string {
+			replied++
+			return ""
+		}
+
+		if err := call("<@bot> "); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if err := call("~"); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if replied != 1 {
+			t.Fatal("unexpected number of replies:", replied)
+		}
+	})
+}
@@ -0,0 +1,411 @@
+package rfrouter
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/pkg/errors"
+)
+
+// invocation is a message that invoked a command, along with the messages that
+// were sent in response to it.
+type invocation struct {
+	channelID string
+	messageID string
+	responses []string
+
+	// previous are the responses of the last run that haven't been edited yet,
+	// if the command is being run again after its message was edited.
+	previous []string
+
+	// content is the message's content when its command last finished, and
+	// newContent is its content in the current run. An edit only runs the
+	// command again if the content changed.
+	content    string
+	newContent string
+
+	// command is true if the message ran a command. Such messages are
+	// remembered even without responses, so their content is known.
+	command bool
+
+	time time.Time
+}
+
+// responseTracker remembers the responses of recent commands, so they can be
+// edited when the command is run again, or deleted along with the command. It
+// is safe for concurrent use.
+type responseTracker struct {
+	mutex sync.Mutex
+
+	// invocations are the commands with responses, keyed by message ID. order
+	// contains their IDs, oldest first.
+	invocations map[string]*invocation
+	order       []string
+
+	// running are the invocations that haven't finished, keyed by channel ID.
+	running map[string][]*invocation
+
+	now func() time.Time
+}
+
+func newResponseTracker() *responseTracker {
+	return &responseTracker{
+		invocations: map[string]*invocation{},
+		running:     map[string][]*invocation{},
+		now:         time.Now,
+	}
+}
+
+// begin marks the message as running a command. The previous responses of an
+// edited message are reused.
+func (t *responseTracker) begin(m *discordgo.Message, edit bool) *invocation {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	inv, ok := t.invocations[m.ID]
+	if !ok || !edit {
+		inv = &invocation{
+			channelID: m.ChannelID,
+			messageID: m.ID,
+			time:      t.now(),
+		}
+	}
+
+	if edit {
+		inv.previous = inv.responses
+		inv.responses = nil
+	}
+
+	inv.newContent = m.Content
+	inv.command = false
+
+	t.running[m.ChannelID] = append(t.running[m.ChannelID], inv)
+	return inv
+}
+
+// end marks the invocation as finished, remembering it if it has any responses.
+// The oldest invocations are forgotten once there are more than max of them,
+// or once they're older than maxAge if it's not 0. The previous responses that
+// weren't edited are returned, as the command no longer sends them.
+func (t *responseTracker) end(
+	inv *invocation, max int, maxAge time.Duration) (unused []string) {
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	var running = t.running[inv.channelID]
+	for i, r := range running {
+		if r == inv {
+			running = append(running[:i], running[i+1:]...)
+			break
+		}
+	}
+
+	if len(running) == 0 {
+		delete(t.running, inv.channelID)
+	} else {
+		t.running[inv.channelID] = running
+	}
+
+	unused, inv.previous = inv.previous, nil
+	inv.content = inv.newContent
+
+	_, ok := t.invocations[inv.messageID]
+	var keep = inv.command || len(inv.responses) > 0
+
+	switch {
+	case !ok && keep:
+		t.invocations[inv.messageID] = inv
+		t.order = append(t.order, inv.messageID)
+	case ok && !keep:
+		t.remove(inv.messageID)
+	}
+
+	for len(t.order) > 0 && (len(t.order) > max || t.expired(t.order[0], maxAge)) {
+		delete(t.invocations, t.order[0])
+		t.order = t.order[1:]
+	}
+
+	return unused
+}
+
+// remove forgets the invocation of the message. The mutex must be held.
+func (t *responseTracker) remove(messageID string) {
+	delete(t.invocations, messageID)
+
+	for i, id := range t.order {
+		if id == messageID {
+			t.order = append(t.order[:i], t.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// expired returns true if the remembered invocation is older than maxAge. The
+// mutex must be held.
+func (t *responseTracker) expired(messageID string, maxAge time.Duration) bool {
+	return maxAge > 0 && t.now().Sub(t.invocations[messageID].time) > maxAge
+}
+
+// forget forgets the invocation of the message, returning the IDs of its
+// responses. Nothing is returned if the invocation is older than maxAge.
+func (t *responseTracker) forget(messageID string, maxAge time.Duration) []string {
+	if t == nil {
+		return nil
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	inv, ok := t.invocations[messageID]
+	if !ok {
+		return nil
+	}
+
+	var expired = t.expired(messageID, maxAge)
+
+	t.remove(messageID)
+
+	if expired {
+		return nil
+	}
+
+	return inv.responses
+}
+
+// current returns the running invocation in the channel, or nil. If messageID
+// is empty, the invocation is only returned if it's the only one running
+// there, as there's no telling which command a response belongs to otherwise.
+func (t *responseTracker) current(channelID, messageID string) *invocation {
+	if t == nil {
+		return nil
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	var running = t.running[channelID]
+
+	if messageID == "" {
+		if len(running) == 1 {
+			return running[0]
+		}
+
+		return nil
+	}
+
+	for _, inv := range running {
+		if inv.messageID == messageID {
+			return inv
+		}
+	}
+
+	return nil
+}
+
+// nextEdit returns the ID of the previous response that should be edited
+// instead of sending a new message, if there's any. The response is kept as a
+// response of this run.
+func (t *responseTracker) nextEdit(inv *invocation) (string, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if len(inv.previous) == 0 {
+		return "", false
+	}
+
+	var id = inv.previous[0]
+	inv.previous = inv.previous[1:]
+	inv.responses = append(inv.responses, id)
+
+	return id, true
+}
+
+// markCommand marks the running invocation of the message as having run a
+// command.
+func (t *responseTracker) markCommand(channelID, messageID string) {
+	if inv := t.current(channelID, messageID); inv != nil {
+		t.mutex.Lock()
+		inv.command = true
+		t.mutex.Unlock()
+	}
+}
+
+// changed returns true if the message's content isn't the same as when its
+// command last ran, or if that isn't known.
+func (t *responseTracker) changed(m *discordgo.Message) bool {
+	if t == nil {
+		return true
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	inv, ok := t.invocations[m.ID]
+	return !ok || inv.content != m.Content
+}
+
+// add records a new response of the invocation.
+func (t *responseTracker) add(inv *invocation, messageID string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	inv.responses = append(inv.responses, messageID)
+}
+
+// isEdit returns true if the update is an edit of the message's content, as
+// opposed to an update such as a link's embed loading.
+func isEdit(mu *discordgo.MessageUpdate) bool {
+	return mu.Message != nil && mu.EditedTimestamp != ""
+}
+
+// rerun returns true if the update is an edit that should run the message's
+// command again. Updates of edited messages, such as pinning one, still have
+// the edited timestamp, so the content has to have changed as well.
+func (ctx *Context) rerun(mu *discordgo.MessageUpdate) bool {
+	return ctx.EditCommands && isEdit(mu) && ctx.responses.changed(mu.Message)
+}
+
+// trackedMessage returns the message that an event handled by Start may run a
+// command from, or nil.
+func (ctx *Context) trackedMessage(ev interface{}) (m *discordgo.Message, edit bool) {
+	if ctx.responses == nil {
+		return nil, false
+	}
+
+	switch ev := ev.(type) {
+	case *discordgo.MessageCreate:
+		return ev.Message, false
+	case *discordgo.MessageUpdate:
+		if ctx.rerun(ev) {
+			return ev.Message, true
+		}
+	}
+
+	return nil, false
+}
+
+// send sends the content as a response to the invocation, editing a previous
+// response instead if the command is being run again. inv may be nil.
+func (ctx *Context) send(inv *invocation,
+	channelID string, content interface{}) (*discordgo.Message, error) {
+
+	if inv != nil && editable(content) {
+		if id, ok := ctx.responses.nextEdit(inv); ok {
+			return ctx.edit(channelID, id, content)
+		}
+	}
+
+	var m *discordgo.Message
+	var err error
+
+	switch content := content.(type) {
+	case string:
+		m, err = ctx.Session.ChannelMessageSend(channelID, content)
+	case *discordgo.MessageEmbed:
+		m, err = ctx.Session.ChannelMessageSendEmbed(channelID, content)
+	case *discordgo.MessageSend:
+		m, err = ctx.Session.ChannelMessageSendComplex(channelID, content)
+	default:
+		// BUG
+		panic("Send received an unknown content type")
+	}
+
+	if err == nil && inv != nil {
+		ctx.responses.add(inv, m.ID)
+	}
+
+	return m, err
+}
+
+// messageEdit replaces both the content and the embed of a message. Unlike
+// discordgo.MessageEdit, a nil Embed removes the message's embed.
+type messageEdit struct {
+	Content string                  `json:"content"`
+	Embed   *discordgo.MessageEmbed `json:"embed"`
+}
+
+// edit replaces the content of a previous response.
+func (ctx *Context) edit(channelID, messageID string,
+	content interface{}) (*discordgo.Message, error) {
+
+	var edit messageEdit
+
+	switch content := content.(type) {
+	case string:
+		edit.Content = content
+	case *discordgo.MessageEmbed:
+		edit.Embed = content
+	case *discordgo.MessageSend:
+		edit.Content = content.Content
+		edit.Embed = content.Embed
+	default:
+		// BUG
+		panic("Send received an unknown content type")
+	}
+
+	if edit.Embed != nil && edit.Embed.Type == "" {
+		edit.Embed.Type = "rich"
+	}
+
+	b, err := ctx.Session.RequestWithBucketID("PATCH",
+		discordgo.EndpointChannelMessage(channelID, messageID), edit,
+		discordgo.EndpointChannelMessage(channelID, ""))
+	if err != nil {
+		return nil, err
+	}
+
+	var m *discordgo.Message
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, errors.Wrap(err, "Failed to decode the edited message")
+	}
+
+	return m, nil
+}
+
+// editable returns true if a previous response can be edited into the content.
+// Files can't be added to existing messages.
+func editable(content interface{}) bool {
+	send, ok := content.(*discordgo.MessageSend)
+	return !ok || len(send.Files) == 0 && send.File == nil
+}
+
+// maxBulkDelete is the maximum number of messages that can be deleted at once.
+const maxBulkDelete = 100
+
+// deleteResponses deletes the responses to the deleted messages in the channel.
+func (ctx *Context) deleteResponses(channelID string, messageIDs ...string) error {
+	var responses []string
+	for _, id := range messageIDs {
+		responses = append(responses, ctx.responses.forget(id, ctx.TrackedCommandsAge)...)
+	}
+
+	return ctx.deleteMessages(channelID, responses)
+}
+
+// deleteMessages deletes the bot's messages in the channel.
+func (ctx *Context) deleteMessages(channelID string, responses []string) error {
+	for len(responses) > 0 {
+		var n = len(responses)
+		if n > maxBulkDelete {
+			n = maxBulkDelete
+		}
+
+		// Bulk deletes need Manage Messages, don't work in direct messages and
+		// fail for messages older than two weeks, while the bot can always
+		// delete its own messages one by one.
+		if ctx.Session.ChannelMessagesBulkDelete(channelID, responses[:n]) != nil {
+			for _, id := range responses[:n] {
+				if err := ctx.Session.ChannelMessageDelete(channelID, id); err != nil {
+					return errors.Wrap(err, "Failed to delete responses")
+				}
+			}
+		}
+
+		responses = responses[n:]
+	}
+
+	return nil
+}
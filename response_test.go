@@ -0,0 +1,304 @@
+package rfrouter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type editCommands struct {
+	Ctx   *Context
+	Calls []string
+}
+
+func (e *editCommands) Echo(_ *discordgo.MessageCreate, text Remaining) error {
+	e.Calls = append(e.Calls, string(text))
+	return nil
+}
+
+func TestEditCommands(t *testing.T) {
+	var cmds = &editCommands{}
+
+	ctx, err := New(&discordgo.Session{}, cmds)
+	if err != nil {
+		t.Fatal("Failed to create new context:", err)
+	}
+
+	var update = func(content string, edited bool) error {
+		var m = &discordgo.Message{Content: content}
+		if edited {
+			m.EditedTimestamp = "2020-01-01T00:00:00+00:00"
+		}
+
+		return ctx.Call(&discordgo.MessageUpdate{Message: m})
+	}
+
+	if err := update("~echo hi", true); err != nil || len(cmds.Calls) != 0 {
+		t.Fatal("unexpected call with EditCommands disabled:", cmds.Calls, err)
+	}
+
+	ctx.EditCommands = true
+
+	if err := update("~echo hi", false); err != nil || len(cmds.Calls) != 0 {
+		t.Fatal("unexpected call for an update that isn't an edit:", cmds.Calls, err)
+	}
+
+	if err := update("~echo hi", true); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if !reflect.DeepEqual(cmds.Calls, []string{"hi"}) {
+		t.Fatal("unexpected calls:", cmds.Calls)
+	}
+
+	if _, ok := update("~ehco hi", true).(*ErrUnknownCommand); !ok {
+		t.Fatal("expected unknown command error for an edit")
+	}
+
+	t.Run("unchanged", func(t *testing.T) {
+		cmds.Calls = nil
+
+		// run handles the update like Start does.
+		var run = func(content string) {
+			var ev = &discordgo.MessageUpdate{Message: &discordgo.Message{
+				ID:              "message",
+				Content:         content,
+				EditedTimestamp: "2020-01-01T00:00:00+00:00",
+			}}
+
+			if m, edit := ctx.trackedMessage(ev); m != nil {
+				inv := ctx.responses.begin(m, edit)
+				defer ctx.responses.end(inv, 10, 0)
+			}
+
+			if err := ctx.Call(ev); err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+		}
+
+		// Updates of edited messages, such as pinning one, keep the edited
+		// timestamp without changing the content.
+		for _, content := range []string{"~echo a", "~echo a", "~echo b", "~echo b"} {
+			run(content)
+		}
+
+		if !reflect.DeepEqual(cmds.Calls, []string{"a", "b"}) {
+			t.Fatal("unexpected calls:", cmds.Calls)
+		}
+	})
+}
+
+func TestResponseTracker(t *testing.T) {
+	var tracker = newResponseTracker()
+
+	var message = func(id string) *discordgo.Message {
+		return &discordgo.Message{ID: id, ChannelID: "channel"}
+	}
+
+	inv := tracker.begin(message("a"), false)
+
+	if tracker.current("channel", "") != inv || tracker.current("channel", "a") != inv {
+		t.Fatal("running invocation not found")
+	}
+
+	if _, ok := tracker.nextEdit(inv); ok {
+		t.Fatal("unexpected edit on the first run")
+	}
+
+	tracker.add(inv, "reply 1")
+	tracker.add(inv, "reply 2")
+
+	// Responses are ambiguous with more than one command running.
+	other := tracker.begin(message("b"), false)
+
+	if tracker.current("channel", "") != nil {
+		t.Fatal("unexpected invocation with two running")
+	}
+
+	if tracker.current("channel", "b") != other {
+		t.Fatal("invocation not found by message ID")
+	}
+
+	tracker.end(other, 10, 0)
+	tracker.end(inv, 10, 0)
+
+	if tracker.current("channel", "a") != nil {
+		t.Fatal("unexpected running invocation after end")
+	}
+
+	if len(tracker.invocations) != 1 {
+		t.Fatal("only invocations with responses should be kept:", tracker.invocations)
+	}
+
+	t.Run("edit", func(t *testing.T) {
+		inv := tracker.begin(message("a"), true)
+
+		for _, expect := range []string{"reply 1", "reply 2"} {
+			if id, ok := tracker.nextEdit(inv); !ok || id != expect {
+				t.Fatal("unexpected edit:", id, ok)
+			}
+		}
+
+		if _, ok := tracker.nextEdit(inv); ok {
+			t.Fatal("unexpected edit after all responses")
+		}
+
+		if unused := tracker.end(inv, 10, 0); len(unused) != 0 {
+			t.Fatal("unexpected unused responses:", unused)
+		}
+	})
+
+	t.Run("unused", func(t *testing.T) {
+		inv := tracker.begin(message("a"), true)
+		tracker.nextEdit(inv)
+
+		if unused := tracker.end(inv, 10, 0); !reflect.DeepEqual(unused, []string{"reply 2"}) {
+			t.Fatal("unexpected unused responses:", unused)
+		}
+
+		if r := tracker.invocations["a"].responses; !reflect.DeepEqual(r, []string{"reply 1"}) {
+			t.Fatal("unexpected responses after editing:", r)
+		}
+
+		// The invocation is forgotten once it has no responses left.
+		inv = tracker.begin(message("a"), true)
+
+		if unused := tracker.end(inv, 10, 0); !reflect.DeepEqual(unused, []string{"reply 1"}) {
+			t.Fatal("unexpected unused responses:", unused)
+		}
+
+		if len(tracker.invocations) != 0 || len(tracker.order) != 0 {
+			t.Fatal("unexpected invocations:", tracker.order)
+		}
+	})
+
+	t.Run("evict", func(t *testing.T) {
+		for _, id := range []string{"c", "d", "e"} {
+			inv := tracker.begin(message(id), false)
+			tracker.add(inv, "reply")
+			tracker.end(inv, 2, 0)
+		}
+
+		if !reflect.DeepEqual(tracker.order, []string{"d", "e"}) {
+			t.Fatal("unexpected invocations after eviction:", tracker.order)
+		}
+
+		if len(tracker.invocations) != 2 {
+			t.Fatal("unexpected invocations:", tracker.invocations)
+		}
+	})
+
+	t.Run("forget", func(t *testing.T) {
+		if r := tracker.forget("d", time.Hour); !reflect.DeepEqual(r, []string{"reply"}) {
+			t.Fatal("unexpected responses:", r)
+		}
+
+		if r := tracker.forget("d", time.Hour); r != nil {
+			t.Fatal("unexpected responses after forgetting:", r)
+		}
+
+		if !reflect.DeepEqual(tracker.order, []string{"e"}) {
+			t.Fatal("unexpected invocations after forgetting:", tracker.order)
+		}
+	})
+
+	t.Run("expire", func(t *testing.T) {
+		var now = time.Now()
+		tracker.now = func() time.Time { return now }
+
+		inv := tracker.begin(message("f"), false)
+		tracker.add(inv, "reply")
+		tracker.end(inv, 10, time.Minute)
+
+		now = now.Add(2 * time.Minute)
+
+		if r := tracker.forget("f", time.Minute); r != nil {
+			t.Fatal("unexpected responses of an expired invocation:", r)
+		}
+
+		inv = tracker.begin(message("g"), false)
+		tracker.add(inv, "reply")
+		tracker.end(inv, 10, time.Minute)
+
+		// "e" was tracked with the real time, so it's long expired by now.
+		if !reflect.DeepEqual(tracker.order, []string{"g"}) {
+			t.Fatal("unexpected invocations after expiry:", tracker.order)
+		}
+	})
+}
+
+func TestMessageEdit(t *testing.T) {
+	b, err := json.Marshal(messageEdit{Content: "text"})
+	if err != nil {
+		t.Fatal("Failed to marshal:", err)
+	}
+
+	// The embed has to be sent as null to be removed.
+	if string(b) != `{"content":"text","embed":null}` {
+		t.Fatal("unexpected edit:", string(b))
+	}
+}
+
+func TestDeleteResponses(t *testing.T) {
+	var mutex sync.Mutex
+	var deleted []string
+
+	// The server refuses bulk deletes, like Discord does in direct messages.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if r.Method != "DELETE" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		deleted = append(deleted, r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:])
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	var endpoint = discordgo.EndpointChannels
+	discordgo.EndpointChannels = srv.URL + "/channels/"
+	defer func() { discordgo.EndpointChannels = endpoint }()
+
+	session, err := discordgo.New("Bot token")
+	if err != nil {
+		t.Fatal("Failed to create session:", err)
+	}
+
+	ctx, err := New(session, &editCommands{})
+	if err != nil {
+		t.Fatal("Failed to create new context:", err)
+	}
+
+	ctx.DeleteResponses = true
+	ctx.ErrorLogger = func(err error) {
+		t.Fatal("unexpected error:", err)
+	}
+
+	var m = &discordgo.Message{ID: "command", ChannelID: "dm"}
+
+	inv := ctx.responses.begin(m, false)
+	ctx.responses.add(inv, "reply 1")
+	ctx.responses.add(inv, "reply 2")
+	ctx.responses.end(inv, 10, 0)
+
+	if err := ctx.Call(&discordgo.MessageDelete{Message: m}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if !reflect.DeepEqual(deleted, []string{"reply 1", "reply 2"}) {
+		t.Fatal("unexpected deleted responses:", deleted)
+	}
+}
@@ -10,22 +10,46 @@ import (
 
 var (
 	typeMessageCreate = reflect.TypeOf((*discordgo.MessageCreate)(nil))
+	typeRemaining     = reflect.TypeOf(Remaining(""))
 	// typeof.Implements(typeI*)
-	typeIError  = reflect.TypeOf((*error)(nil)).Elem()
-	typeIManP   = reflect.TypeOf((*ManualParseable)(nil)).Elem()
-	typeIParser = reflect.TypeOf((*Parseable)(nil)).Elem()
-	typeIUsager = reflect.TypeOf((*Usager)(nil)).Elem()
+	typeIError    = reflect.TypeOf((*error)(nil)).Elem()
+	typeIManP     = reflect.TypeOf((*ManualParseable)(nil)).Elem()
+	typeIParser   = reflect.TypeOf((*Parseable)(nil)).Elem()
+	typeIUsager   = reflect.TypeOf((*Usager)(nil)).Elem()
+	typeIOptional = reflect.TypeOf((*Optional)(nil)).Elem()
 )
 
 type Subcommand struct {
 	Description string
 
+	// Aliases contains alternative names for the subcommand.
+	Aliases []string
+
+	// Permissions are the Discord permissions that both the user and the bot
+	// need to use any command in this subcommand.
+	Permissions int
+
+	// Roles contains the role IDs that the user needs one of to use any
+	// command in this subcommand, or nil for no restriction.
+	Roles []string
+
 	// Commands contains all the registered command contexts.
 	Commands []*CommandContext
 
+	// Subcommands contains all the registered child subcommands.
+	Subcommands []*Subcommand
+
 	// struct name
 	name string
 
+	// the context and the subcommand this one is registered to, nil for the
+	// root
+	ctx    *Context
+	parent *Subcommand
+
+	// middlewares around the commands, added with Use
+	middlewares []Middleware
+
 	// struct flags
 	Flag NameFlag
 
@@ -48,7 +72,26 @@ type CommandContext struct {
 	Description string
 	Flag        NameFlag
 
+	// Aliases contains alternative names for the command.
+	Aliases []string
+
+	// Examples contains example arguments for the command, without the
+	// prefix and the command's name.
+	Examples []string
+
+	// Cooldown limits how often the command can be used, or nil for no limit.
+	Cooldown *Cooldown
+
+	// Permissions are the Discord permissions that both the user and the bot
+	// need to use the command.
+	Permissions int
+
+	// Roles contains the role IDs that the user needs one of to use the
+	// command, or nil for no restriction.
+	Roles []string
+
 	name   string        // all lower-case
+	parent *Subcommand   // the subcommand this command belongs to
 	value  reflect.Value // Func
 	event  reflect.Type  // discordgo.*
 	method reflect.Method
@@ -57,6 +100,13 @@ type CommandContext struct {
 	argStrings []string
 	arguments  []argumentValueFn
 
+	// number of trailing arguments that may be omitted, excluding variadic
+	optional int
+	// true if the last argument takes all remaining tokens
+	variadic bool
+	// true if the last argument takes the rest of the content
+	remaining bool
+
 	parseMethod reflect.Method
 	parseType   reflect.Type
 	parseUsage  string
@@ -67,11 +117,36 @@ type Descriptor interface {
 	Description() string
 }
 
+// CommandDescriber is optionally used to set the descriptions of commands. The
+// returned map's keys are command names, such as "help".
+type CommandDescriber interface {
+	CommandDescriptions() map[string]string
+}
+
+// CommandExampler is optionally used to give commands usage examples, which are
+// shown in their help. The returned map's keys are command names, and its
+// values are example arguments, such as "@user 7" for "ban".
+type CommandExampler interface {
+	CommandExamples() map[string][]string
+}
+
 // Namer is optionally used to override the command context's name.
 type Namer interface {
 	Name() string
 }
 
+// Aliaser is optionally used to give a subcommand alternative names.
+type Aliaser interface {
+	Aliases() []string
+}
+
+// CommandAliaser is optionally used to give commands alternative names. The
+// returned map's keys are command names, such as "help", and its values are
+// the aliases for that command.
+type CommandAliaser interface {
+	CommandAliases() map[string][]string
+}
+
 // Usager is optionally used to override the generated usage for either an
 // argument, or multiple (using ManualParseable).
 type Usager interface {
@@ -104,6 +179,14 @@ func NewSubcommand(cmd interface{}) (*Subcommand, error) {
 		sub.Description = d.Description()
 	}
 
+	if p, ok := cmd.(PermissionRequirer); ok {
+		sub.Permissions = p.RequiredPermissions()
+	}
+
+	if r, ok := cmd.(RoleRequirer); ok {
+		sub.Roles = r.RequiredRoles()
+	}
+
 	if err := sub.reflectCommands(); err != nil {
 		return nil, errors.Wrap(err, "Failed to reflect commands")
 	}
@@ -121,6 +204,64 @@ func (sub *Subcommand) Name() string {
 	return sub.name
 }
 
+// Path returns the names of this subcommand and all of its parents, starting
+// from the top-most one. The root subcommand of a Context has an empty path.
+func (sub *Subcommand) Path() []string {
+	if sub.parent == nil {
+		return nil
+	}
+
+	return append(sub.parent.Path(), sub.name)
+}
+
+// RegisterSubcommand registers cmd as a child subcommand of this one. The
+// subcommand must already be registered itself, or be the root of a Context.
+func (sub *Subcommand) RegisterSubcommand(cmd interface{}) (*Subcommand, error) {
+	if sub.ctx == nil {
+		return nil, errors.New("Subcommand is not initialized with a Context")
+	}
+
+	s, err := NewSubcommand(cmd)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to add subcommand")
+	}
+
+	// Register the subcommand's name.
+	s.NeedsName()
+
+	if err := s.InitCommands(sub.ctx); err != nil {
+		return nil, errors.Wrap(err, "Failed to initialize subcommand")
+	}
+
+	// Do a collision check, including aliases
+	for _, child := range sub.Subcommands {
+		if child.hasName(s.name) {
+			return nil, errors.New(
+				"New subcommand has duplicate name: " + s.name)
+		}
+
+		for _, alias := range s.Aliases {
+			if child.hasName(alias) {
+				return nil, errors.New(
+					"New subcommand has duplicate alias: " + alias)
+			}
+		}
+	}
+
+	// Commands are looked up first, so one with the same name would hide the
+	// subcommand.
+	for _, name := range append([]string{s.name}, s.Aliases...) {
+		if sub.findMessageCommand(name) != nil {
+			return nil, errors.New(
+				"New subcommand has the name of a command: " + name)
+		}
+	}
+
+	s.parent = sub
+	sub.Subcommands = append(sub.Subcommands, s)
+	return s, nil
+}
+
 // NeedsName sets the name for this subcommand. Like InitCommands, this
 // shouldn't be called at all, rather you should use RegisterSubcommand.
 func (sub *Subcommand) NeedsName() {
@@ -131,8 +272,13 @@ func (sub *Subcommand) NeedsName() {
 		name = n.Name()
 	}
 
+	if a, ok := sub.command.(Aliaser); ok {
+		sub.Aliases = a.Aliases()
+	}
+
 	if !flag.Is(Raw) {
 		name = strings.ToLower(name)
+		sub.Aliases = lowerAll(sub.Aliases)
 	}
 
 	sub.name = name
@@ -166,8 +312,10 @@ func (sub *Subcommand) reflectCommands() error {
 }
 
 // InitCommands fills a Subcommand with a context. This shouldn't be called at
-// all, rather you should use the RegisterSubcommand method of a Context.
+// all, rather you should use the RegisterSubcommand method.
 func (sub *Subcommand) InitCommands(ctx *Context) error {
+	sub.ctx = ctx
+
 	// Start filling up a *Context field
 	for i := 0; i < sub.cmdValue.NumField(); i++ {
 		field := sub.cmdValue.Field(i)
@@ -213,6 +361,7 @@ func (sub *Subcommand) parseCommands() error {
 		}
 
 		var command = CommandContext{
+			parent: sub,
 			method: sub.ptrType.Method(i),
 			value:  method,
 			event:  methodT.In(0), // parse event
@@ -259,6 +408,12 @@ func (sub *Subcommand) parseCommands() error {
 		for i := 1; i < numArgs; i++ {
 			t := methodT.In(i)
 
+			// Variadic arguments are parsed one by one with the element type.
+			variadic := methodT.IsVariadic() && i == numArgs-1
+			if variadic {
+				t = t.Elem()
+			}
+
 			avfs, err := getArgumentValueFn(t)
 			if err != nil {
 				return errors.Wrap(err, "Error parsing argument "+t.String())
@@ -268,7 +423,27 @@ func (sub *Subcommand) parseCommands() error {
 
 			var usage = usager(t)
 			if usage == "" {
-				usage = t.String()
+				usage = typeUsage(t)
+			}
+
+			switch {
+			case variadic:
+				command.variadic = true
+				usage += "..."
+			case t == typeRemaining:
+				if i != numArgs-1 {
+					return errors.New("Remaining is not the last argument in " +
+						command.method.Name)
+				}
+
+				command.remaining = true
+				usage = "text..."
+			case isOptional(t):
+				command.optional++
+				usage = "[" + usage + "]"
+			case command.optional > 0:
+				return errors.New("Required argument " + t.String() +
+					" follows an optional one in " + command.method.Name)
 			}
 
 			command.argStrings = append(command.argStrings, usage)
@@ -280,6 +455,116 @@ func (sub *Subcommand) parseCommands() error {
 	}
 
 	sub.Commands = commands
+
+	if a, ok := sub.command.(CommandAliaser); ok {
+		var aliases = a.CommandAliases()
+
+		err := sub.forEachNamed("Aliases", aliases, func(name string, cmd *CommandContext) {
+			var aliases = aliases[name]
+			if !cmd.Flag.Is(Raw) {
+				aliases = lowerAll(aliases)
+			}
+
+			cmd.Aliases = append(cmd.Aliases, aliases...)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Do a collision check, including aliases. Event handlers aren't called
+	// by name, so they're left out.
+	var names = map[string]bool{}
+	for _, cmd := range commands {
+		if cmd.event != typeMessageCreate {
+			continue
+		}
+
+		for _, name := range append([]string{cmd.name}, cmd.Aliases...) {
+			if names[name] {
+				return errors.New("Duplicate command name or alias: " + name)
+			}
+
+			names[name] = true
+		}
+	}
+
+	if c, ok := sub.command.(CommandCooldowner); ok {
+		var cooldowns = c.CommandCooldowns()
+
+		err := sub.forEachNamed("Cooldown", cooldowns, func(name string, cmd *CommandContext) {
+			cooldown := cooldowns[name]
+			cmd.Cooldown = &cooldown
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if p, ok := sub.command.(CommandPermissioner); ok {
+		var perms = p.CommandPermissions()
+
+		err := sub.forEachNamed("Permissions", perms, func(name string, cmd *CommandContext) {
+			cmd.Permissions = perms[name]
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if r, ok := sub.command.(CommandRoler); ok {
+		var roles = r.CommandRoles()
+
+		err := sub.forEachNamed("Roles", roles, func(name string, cmd *CommandContext) {
+			cmd.Roles = roles[name]
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if d, ok := sub.command.(CommandDescriber); ok {
+		var descriptions = d.CommandDescriptions()
+
+		err := sub.forEachNamed("Description", descriptions, func(name string, cmd *CommandContext) {
+			cmd.Description = descriptions[name]
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if e, ok := sub.command.(CommandExampler); ok {
+		var examples = e.CommandExamples()
+
+		err := sub.forEachNamed("Examples", examples, func(name string, cmd *CommandContext) {
+			cmd.Examples = examples[name]
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// forEachNamed calls fn for each command named by the keys of names, a map
+// given by one of the Command interfaces. kind is what the map gives, for the
+// error if a command is unknown.
+func (sub *Subcommand) forEachNamed(kind string, names interface{},
+	fn func(name string, cmd *CommandContext)) error {
+
+	for _, key := range reflect.ValueOf(names).MapKeys() {
+		var name = key.String()
+
+		cmd := sub.findCommand(name)
+		if cmd == nil {
+			return errors.New(kind + " given for unknown command: " + name)
+		}
+
+		fn(name, cmd)
+	}
+
 	return nil
 }
 
@@ -294,3 +579,77 @@ func usager(t reflect.Type) string {
 	})
 	return v[0].String()
 }
+
+// typeUsage returns the name of the type, with the pointer stripped if it's
+// only there to make the argument optional.
+func typeUsage(t reflect.Type) string {
+	if t.Kind() == reflect.Ptr && !t.Implements(typeIParser) {
+		t = t.Elem()
+	}
+
+	return t.String()
+}
+
+// findCommand returns the command with the given name or alias, or nil.
+func (sub *Subcommand) findCommand(name string) *CommandContext {
+	for _, c := range sub.Commands {
+		if c.hasName(name) {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// findMessageCommand returns the command with the given name or alias that is
+// called from messages, or nil. Event handlers aren't called by name.
+func (sub *Subcommand) findMessageCommand(name string) *CommandContext {
+	for _, c := range sub.Commands {
+		if c.event == typeMessageCreate && c.hasName(name) {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// findSubcommand returns the child subcommand with the given name or alias, or
+// nil.
+func (sub *Subcommand) findSubcommand(name string) *Subcommand {
+	for _, s := range sub.Subcommands {
+		if s.hasName(name) {
+			return s
+		}
+	}
+
+	return nil
+}
+
+// hasName returns true if name is the subcommand's name or one of its aliases.
+func (sub *Subcommand) hasName(name string) bool {
+	return sub.name == name || contains(sub.Aliases, name)
+}
+
+// hasName returns true if name is the command's name or one of its aliases.
+func (cctx *CommandContext) hasName(name string) bool {
+	return cctx.name == name || contains(cctx.Aliases, name)
+}
+
+func contains(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+
+	return false
+}
+
+func lowerAll(strs []string) []string {
+	var lower = make([]string, len(strs))
+	for i, s := range strs {
+		lower[i] = strings.ToLower(s)
+	}
+
+	return lower
+}
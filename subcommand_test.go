@@ -1,6 +1,12 @@
 package rfrouter
 
-import "testing"
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
 
 func TestNewSubcommand(t *testing.T) {
 	_, err := NewSubcommand(&testCommands{})
@@ -27,7 +33,7 @@ func TestSubcommand(t *testing.T) {
 		}
 
 		// !!! CHANGE ME
-		if len(sub.Commands) != 4 {
+		if len(sub.Commands) != 7 {
 			t.Fatal("invalid ctx.commands len", len(sub.Commands))
 		}
 
@@ -63,6 +69,31 @@ func TestSubcommand(t *testing.T) {
 					t.Fatal("unexpected parseType")
 				}
 
+			case "optional":
+				if this.optional != 1 {
+					t.Fatal("expected 1 optional argument, got", this.optional)
+				}
+
+				expect := []string{"string", "[int]"}
+				if usage := this.Usage(); !reflect.DeepEqual(usage, expect) {
+					t.Fatal("unexpected usage:", usage)
+				}
+
+			case "sum":
+				if !this.variadic {
+					t.Fatal("expected sum to be variadic")
+				}
+
+				expect := []string{"string", "int..."}
+				if usage := this.Usage(); !reflect.DeepEqual(usage, expect) {
+					t.Fatal("unexpected usage:", usage)
+				}
+
+			case "note":
+				if !this.remaining {
+					t.Fatal("expected note to take the remaining content")
+				}
+
 			case "noop":
 				// Found, but whatever
 
@@ -89,8 +120,112 @@ func TestSubcommand(t *testing.T) {
 	})
 }
 
+type collidingCommands struct {
+	Ctx *Context
+}
+
+func (c *collidingCommands) Ping(_ *discordgo.MessageCreate) error {
+	return nil
+}
+
+func (c *collidingCommands) Pong(_ *discordgo.MessageCreate) error {
+	return nil
+}
+
+func (c *collidingCommands) CommandAliases() map[string][]string {
+	return map[string][]string{
+		"pong": {"ping"},
+	}
+}
+
+// sum has the name of a testCommands command.
+type sum struct {
+	Ctx *Context
+}
+
+func (s *sum) Add(_ *discordgo.MessageCreate) error {
+	return nil
+}
+
+// idle has the alias of a testCommands command.
+type idle struct {
+	Ctx *Context
+}
+
+func (i *idle) Aliases() []string {
+	return []string{"nop"}
+}
+
+func (i *idle) Add(_ *discordgo.MessageCreate) error {
+	return nil
+}
+
+func TestCollisions(t *testing.T) {
+	if _, err := New(newTestSession(t), &collidingCommands{}); err == nil {
+		t.Fatal("expected an error for an alias colliding with a command")
+	}
+
+	ctx, err := New(newTestSession(t), &testCommands{})
+	if err != nil {
+		t.Fatal("Failed to create new context:", err)
+	}
+
+	for _, sub := range []interface{}{&sum{}, &idle{}} {
+		if _, err := ctx.RegisterSubcommand(sub); err == nil {
+			t.Fatalf("expected an error for %T colliding with a command", sub)
+		}
+	}
+}
+
 func BenchmarkSubcommandConstructor(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		NewSubcommand(&testCommands{})
 	}
 }
+
+// onTyping has the name of a panicCommands event handler.
+type onTyping struct {
+	Ctx *Context
+}
+
+func (o *onTyping) Status(_ *discordgo.MessageCreate) error {
+	return nil
+}
+
+func TestEventHandlerNames(t *testing.T) {
+	ctx, err := New(newTestSession(t), &panicCommands{})
+	if err != nil {
+		t.Fatal("Failed to create new context:", err)
+	}
+
+	var call = func(content string) error {
+		return ctx.Call(&discordgo.MessageCreate{
+			Message: &discordgo.Message{Content: content},
+		})
+	}
+
+	// Event handlers can't be called by name.
+	if _, ok := call("~ontyping").(*ErrUnknownCommand); !ok {
+		t.Fatal("expected unknown command error for an event handler")
+	}
+
+	if unknown, ok := call("~ontypign").(*ErrUnknownCommand); !ok || len(unknown.Suggestions) > 0 {
+		t.Fatal("expected no suggestions for an event handler, got", unknown)
+	}
+
+	if help := ctx.Help(); strings.Contains(help, "~ontyping\n") {
+		t.Fatal("event handler shown in help:\n" + help)
+	}
+
+	if _, err := ctx.CommandHelp(nil, "ontyping"); err == nil {
+		t.Fatal("expected no help for an event handler")
+	}
+
+	if _, err := ctx.RegisterSubcommand(&onTyping{}); err != nil {
+		t.Fatal("Failed to register subcommand:", err)
+	}
+
+	if err := call("~ontyping status"); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+}
@@ -0,0 +1,134 @@
+package rfrouter
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxSuggestions is the maximum number of suggestions for an unknown command.
+const maxSuggestions = 3
+
+// suggest returns the names and aliases of the subcommand's visible commands
+// and children that are close to name, with the closest ones first. Each
+// suggestion is prefixed with the subcommand's path.
+func suggest(sub *Subcommand, name string) []string {
+	if name == "" {
+		return nil
+	}
+
+	type candidate struct {
+		name string
+		dist int
+	}
+
+	var mode = MatchExact
+	if sub.ctx != nil {
+		mode = sub.ctx.MatchMode
+	}
+
+	name = mode.fold(name)
+
+	var candidates []candidate
+	var maxDist = len([]rune(name)) / 3
+	if maxDist < 1 {
+		maxDist = 1
+	}
+
+	var try = func(names ...string) {
+		var best = candidate{dist: maxDist + 1}
+
+		for _, n := range names {
+			if d := editDistance(name, n); d < best.dist {
+				best = candidate{n, d}
+			}
+		}
+
+		if best.name != "" {
+			candidates = append(candidates, best)
+		}
+	}
+
+	for _, cmd := range sub.Commands {
+		// Event handlers can't be called by name.
+		if cmd.event == typeMessageCreate && !cmd.Flag.Is(AdminOnly|OwnerOnly) {
+			try(append([]string{cmd.name}, cmd.Aliases...)...)
+		}
+	}
+
+	for _, child := range sub.Subcommands {
+		if !child.Flag.Is(AdminOnly | OwnerOnly) {
+			try(append([]string{child.name}, child.Aliases...)...)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].dist != candidates[j].dist {
+			return candidates[i].dist < candidates[j].dist
+		}
+
+		return candidates[i].name < candidates[j].name
+	})
+
+	if len(candidates) > maxSuggestions {
+		candidates = candidates[:maxSuggestions]
+	}
+
+	var prefix = strings.Join(append(sub.Path(), ""), " ")
+	var suggestions = make([]string, len(candidates))
+
+	for i, c := range candidates {
+		suggestions[i] = prefix + c.name
+	}
+
+	return suggestions
+}
+
+// editDistance returns the Damerau-Levenshtein distance between a and b, using
+// the optimal string alignment variant: the number of rune insertions,
+// deletions, substitutions and adjacent transpositions to turn a into b.
+func editDistance(a, b string) int {
+	var ra, rb = []rune(a), []rune(b)
+
+	// d[i][j] is the distance between ra[:i] and rb[:j]
+	var d = make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			var cost = 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			d[i][j] = minInt(
+				d[i-1][j]+1,      // deletion
+				d[i][j-1]+1,      // insertion
+				d[i-1][j-1]+cost, // substitution
+			)
+
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1) // transposition
+			}
+		}
+	}
+
+	return d[len(ra)][len(rb)]
+}
+
+func minInt(ints ...int) int {
+	var m = ints[0]
+	for _, i := range ints[1:] {
+		if i < m {
+			m = i
+		}
+	}
+
+	return m
+}
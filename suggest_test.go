@@ -0,0 +1,28 @@
+package rfrouter
+
+import "testing"
+
+func TestEditDistance(t *testing.T) {
+	type entry struct {
+		A, B   string
+		Expect int
+	}
+
+	var entries = []entry{
+		{"help", "help", 0},
+		{"hepl", "help", 1},
+		{"hlp", "help", 1},
+		{"helpp", "help", 1},
+		{"halp", "help", 1},
+		{"", "help", 4},
+		{"ca", "abc", 3},
+		{"デバッグ", "デバグ", 1},
+	}
+
+	for _, entry := range entries {
+		if d := editDistance(entry.A, entry.B); d != entry.Expect {
+			t.Fatalf("unexpected distance between %q and %q: %d",
+				entry.A, entry.B, d)
+		}
+	}
+}
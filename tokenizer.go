@@ -0,0 +1,164 @@
+package rfrouter
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Token is a single argument parsed from a message's content. Start and End
+// are byte offsets into the content given to ParseArgs, spanning the raw token
+// including its quotes and escape characters.
+type Token struct {
+	Value string
+	Start int
+	End   int
+}
+
+// codeFence is the delimiter of a Discord code block.
+const codeFence = "```"
+
+// ParseArgs splits content into tokens the way a shell would. Tokens are
+// separated by any amount of whitespace, including new lines. Single quotes
+// preserve everything literally, double quotes allow escaping \" and \\, and a
+// backslash outside of quotes escapes any character. Double quotes may appear
+// anywhere in a token, so foo"bar baz" is a single foobar baz token, while
+// single quotes only count at the start of one, so apostrophes in words like
+// "don't" stay as they are. Code blocks surrounded by ``` are kept verbatim as
+// part of the token.
+//
+// This is a variable, so it could be overridden with a different tokenizer.
+var ParseArgs = func(content string) ([]Token, error) {
+	var tokens []Token
+	var value strings.Builder
+
+	var start = -1 // -1 when not inside a token
+
+	for i := 0; i < len(content); {
+		r, w := utf8.DecodeRuneInString(content[i:])
+
+		if start < 0 && !unicode.IsSpace(r) {
+			start = i
+		}
+
+		switch {
+		case strings.HasPrefix(content[i:], codeFence):
+			end := strings.Index(content[i+len(codeFence):], codeFence)
+			if end < 0 {
+				return nil, &ErrSyntax{
+					Content: content,
+					Start:   i,
+					End:     len(content),
+					Err:     "Unterminated code block",
+				}
+			}
+
+			end += i + len(codeFence)*2
+
+			value.WriteString(content[i:end])
+			i = end
+			continue
+
+		case unicode.IsSpace(r):
+			if start >= 0 {
+				tokens = append(tokens, Token{value.String(), start, i})
+				value.Reset()
+				start = -1
+			}
+
+		case r == '\\':
+			// A trailing backslash has nothing to escape, so keep it.
+			if i+w == len(content) {
+				value.WriteRune(r)
+				break
+			}
+
+			next, nw := utf8.DecodeRuneInString(content[i+w:])
+			value.WriteRune(next)
+			w += nw
+
+		case r == '"' || r == '\'' && start == i:
+			end, ok := readQuoted(content, i, &value)
+			if !ok {
+				return nil, &ErrSyntax{
+					Content: content,
+					Start:   i,
+					End:     len(content),
+					Err:     "Unterminated quote",
+				}
+			}
+
+			i = end
+			continue
+
+		default:
+			value.WriteRune(r)
+		}
+
+		i += w
+	}
+
+	if start >= 0 {
+		tokens = append(tokens, Token{value.String(), start, len(content)})
+	}
+
+	return tokens, nil
+}
+
+// readQuoted reads the quoted string starting at content[start] into value. It
+// returns the offset after the closing quote, or false if there's none.
+func readQuoted(content string, start int, value *strings.Builder) (int, bool) {
+	var quote = content[start]
+
+	for i := start + 1; i < len(content); i++ {
+		switch c := content[i]; {
+		case c == quote:
+			return i + 1, true
+
+		case c == '\\' && quote == '"' && i+1 < len(content):
+			// Only the quote and the backslash itself are escapable, so paths
+			// like "C:\Users" survive.
+			if next := content[i+1]; next == '"' || next == '\\' {
+				value.WriteByte(next)
+				i++
+				continue
+			}
+
+			value.WriteByte(c)
+
+		default:
+			value.WriteByte(c)
+		}
+	}
+
+	return 0, false
+}
+
+// tokenValues returns the values of all tokens.
+func tokenValues(tokens []Token) []string {
+	var values = make([]string, len(tokens))
+	for i, token := range tokens {
+		values[i] = token.Value
+	}
+
+	return values
+}
+
+// splitInvalid returns the tokens before the one that caused the syntax error,
+// and the invalid token, which spans the rest of the content.
+func splitInvalid(content string, err *ErrSyntax) ([]Token, Token, error) {
+	tokens, perr := ParseArgs(content[:err.Start])
+	if perr != nil {
+		return nil, Token{}, perr
+	}
+
+	var start = err.Start
+
+	// The invalid token started before the quote, as in foo"bar.
+	if last := len(tokens) - 1; last >= 0 && tokens[last].End == err.Start {
+		start = tokens[last].Start
+		tokens = tokens[:last]
+	}
+
+	return tokens, Token{content[start:], start, len(content)}, nil
+}